@@ -34,6 +34,7 @@ type BenchmarkReport struct {
 
 func main() {
 	submitFlag := flag.Bool("submit", false, "Set this flag to submit the report to the URL")
+	outputFlag := flag.String("output", "", "Write the report as JSON to this file, for use in a GeoDNS capacity file")
 	flag.Parse()
 
 	serverComponents := ServerComponents{
@@ -58,6 +59,10 @@ func main() {
 	fmt.Printf("Memory: Make: %s, Model: %s, Benchmark: %s\n", report.ServerComponents.Memory.Make, report.ServerComponents.Memory.Model, report.Benchmarks.Memory)
 	fmt.Printf("Disk:   Make: %s, Model: %s, Benchmark: %s\n", report.ServerComponents.Disk.Make, report.ServerComponents.Disk.Model, report.Benchmarks.Disk)
 
+	if *outputFlag != "" {
+		writeReport(report, *outputFlag)
+	}
+
 	if *submitFlag {
 		url := "YOUR_REST_URL_HERE"
 		sendReport(report, url)
@@ -97,6 +102,18 @@ func benchmarkDisk() string {
 	return string(output)
 }
 
+func writeReport(report BenchmarkReport, path string) {
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := ioutil.WriteFile(path, jsonData, 0644); err != nil {
+		fmt.Println(err)
+	}
+}
+
 func sendReport(report BenchmarkReport, url string) {
 	jsonData, err := json.Marshal(report)
 	if err != nil {
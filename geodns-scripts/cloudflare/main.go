@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+func main() {
+	membersFile := flag.String("members", "./members.json", "Path, URL or git:<dir>@<ref>:<file> of members.json")
+	servicesFile := flag.String("services", "./services.json", "Path, URL or git:<dir>@<ref>:<file> of services.json")
+	countriesFile := flag.String("countries", "./cloudflare-countries.json", "Path to the Cloudflare countries and regions file")
+	credentialsFile := flag.String("credentials-file", "", "JSON file with the account ID as user and the API token as secret per domain, readable by the owner only")
+	credentialsCommand := flag.String("credentials-command", "", "Command printing the credentials, given the provider and domain as $1 and $2 or {provider} and {domain}")
+	endpoint := flag.String("endpoint", "", "Cloudflare v4 API endpoint, such as a local mock of the API")
+	poolPrefix := flag.String("pool-prefix", "ibp-", "Prefix of the names of the member pools managed by this tool")
+	rateLimit := flag.Float64("rate-limit", geodns.CloudflareRateLimit, "Cloudflare requests per second, shared by all domains")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of a single Cloudflare request")
+	retries := flag.Int("retries", 4, "Retries of rate limited or transiently failed Cloudflare requests")
+	ttl := flag.Int("ttl", 60, "TTL of the load balancer records")
+	answers := flag.Int("answers", 1, "Number of nearest member pools steered to per location")
+	regionPenalty := flag.Float64("region-penalty", 0, "Latency in ms added to members outside the region of a country, preferring members in its region")
+	strictRegions := flag.Bool("strict-regions", false, "Only assign members outside the region of a country when none is in its region")
+	planOnly := flag.Bool("plan", false, "Print the pool and steering changes without applying them")
+	planFile := flag.String("json", "", "Write the pool and steering changes as JSON to this file")
+	prune := flag.Bool("prune", false, "Delete the pools of members no longer in members.json")
+	probation := flag.Duration("probation", 0, "Time a member has to be at the required level before it serves a service, such as 720h")
+	upcoming := flag.Duration("upcoming", 30*24*time.Hour, "Report members starting or stopping to serve a service within this time")
+	flag.Parse()
+
+	// Load Member JSON File
+	members, err := geodns.LoadMembers(*membersFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	// Load Services JSON File
+	services, err := geodns.LoadServices(*servicesFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	// Load Countries JSON File
+	countries, err := geodns.LoadCountries(*countriesFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded countries and regions: %d\n", len(countries.Country))
+
+	credentials := geodns.NewCredentialChain(*credentialsFile, *credentialsCommand)
+	transport := geodns.NewTransport(*rateLimit, *timeout)
+	transport.Retries = *retries
+
+	// Every domain may use its own account
+	providers := make(map[string]*geodns.Cloudflare)
+	var accounts []*geodns.Cloudflare
+	report := &geodns.Report{}
+	var plans []geodns.SteeringPlan
+	var steered []geodns.Member
+	eligibility := geodns.EligibilityOptions{Probation: *probation}
+	for _, name := range services.Names() {
+		service := services.Services[name]
+		host, domain := geodns.SplitServiceName(name)
+
+		// Pools only hold IPv4 origins
+		validMembers := geodns.MembersWithAddress(service.EligibleMembersAt(members, eligibility), "A")
+		fmt.Printf("Service %s: %d valid members from %d listed (level %d)\n", name, len(validMembers), len(service.Members), service.Level())
+		steered = append(steered, validMembers...)
+		geodns.PrintUpcomingEligibility(os.Stdout, name, service.UpcomingEligibility(members, eligibility, *upcoming))
+
+		// Never steer a service to nothing because nobody is eligible
+		if len(validMembers) == 0 {
+			fmt.Printf("Service %s: no valid members, skipping\n", name)
+			report.Add(geodns.Result{Provider: "cloudflare", Domain: domain, Host: host, Action: "assign"}, fmt.Errorf("no valid members"))
+			continue
+		}
+
+		provider, ok := providers[domain]
+		if !ok {
+			creds, err := credentials.Resolve("cloudflare", domain)
+			if err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+				report.Add(geodns.Result{Provider: "cloudflare", Domain: domain, Host: host, Action: "login"}, err)
+				continue
+			}
+			provider = geodns.NewCloudflare(creds.Secret, creds.User)
+			provider.PoolPrefix = *poolPrefix
+			provider.BaseURL = *endpoint
+			provider.Transport = transport
+			providers[domain] = provider
+
+			known := false
+			for _, account := range accounts {
+				known = known || account.AccountID == provider.AccountID
+			}
+			if !known {
+				accounts = append(accounts, provider)
+			}
+		}
+
+		// Assign countries to members
+		assignments := geodns.AssignCountries(countries.Country, validMembers,
+			geodns.AssignOptions{Answers: *answers, RegionPenalty: *regionPenalty, StrictRegions: *strictRegions})
+		geodns.PrintOutOfRegion(os.Stdout, name, assignments)
+
+		plan, err := geodns.NewSteeringPlan(provider, domain, host, validMembers, assignments, geodns.PlanOptions{TTL: *ttl})
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			report.Add(geodns.Result{Provider: provider.Name(), Domain: domain, Host: host, Action: "list"}, err)
+			continue
+		}
+		plan.Print(os.Stdout)
+		plans = append(plans, plan)
+
+		if *planOnly {
+			continue
+		}
+		if err := geodns.ApplySteering(provider, plan, report); err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+		}
+	}
+
+	// Pools are shared by the load balancers of an account, so they are
+	// only pruned once every load balancer has moved off them
+	if *prune {
+		for _, account := range accounts {
+			unused, err := geodns.UnusedPools(account, steered)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				report.Add(geodns.Result{Provider: account.Name(), Action: "list"}, err)
+				continue
+			}
+			for _, pool := range unused {
+				fmt.Printf("  - pool %s\n", pool.Name)
+				if *planOnly {
+					continue
+				}
+				err := account.DeletePool(pool)
+				report.Add(geodns.Result{Provider: account.Name(), Action: geodns.ActionDelete, Country: "pool " + pool.Name}, err)
+				if err != nil {
+					fmt.Printf("%v\n", err)
+				}
+			}
+		}
+	}
+
+	if *planFile != "" {
+		planBytes, err := json.MarshalIndent(plans, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(*planFile, planBytes, 0644)
+		}
+		if err != nil {
+			fmt.Printf("Error writing plan: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	report.Print(os.Stdout)
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+const (
+	// settleDelay lets editors finish writing before a changed file is read.
+	settleDelay = 2 * time.Second
+
+	// retryDelay is the first delay after a failed sync, doubled on every
+	// further failure up to the interval.
+	retryDelay = 30 * time.Second
+)
+
+// runDaemon syncs on every interval and whenever a local configuration file
+// changes, until SIGTERM or SIGINT. A sync in progress is finished before
+// shutting down.
+func runDaemon(cfg config, interval time.Duration) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	var changes <-chan struct{}
+	watcher, err := geodns.WatchFiles(cfg.files())
+	if err != nil {
+		fmt.Printf("Failed to watch configuration files, syncing on the interval only: %v\n", err)
+	} else {
+		defer watcher.Close()
+		changes = watcher.C
+	}
+
+	failures := 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Shutting down\n")
+			return 0
+		case <-changes:
+			fmt.Printf("Configuration changed\n")
+			resetTimer(timer, settleDelay)
+			continue
+		case <-timer.C:
+		}
+
+		fmt.Printf("Starting sync at %s\n", time.Now().UTC().Format(time.RFC3339))
+		err := cfg.sync()
+		if ctx.Err() != nil {
+			fmt.Printf("Shutting down\n")
+			return 0
+		}
+
+		if err != nil {
+			failures++
+			delay := backoff(failures, interval)
+			fmt.Printf("Sync failed: %v, retrying in %s\n", err, delay)
+			timer.Reset(delay)
+			continue
+		}
+
+		failures = 0
+		delay := jitter(interval)
+		fmt.Printf("Sync done, next in %s\n", delay)
+		timer.Reset(delay)
+	}
+}
+
+// resetTimer resets a timer that may still be running.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// backoff returns the delay after the given number of consecutive failures.
+func backoff(failures int, interval time.Duration) time.Duration {
+	delay := retryDelay
+	for i := 1; i < failures && delay < interval; i++ {
+		delay *= 2
+	}
+	if delay > interval {
+		delay = interval
+	}
+	return delay
+}
+
+// jitter spreads the interval by up to 10% either way, so that several
+// daemons don't hit the provider API at the same moment.
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(interval / 10)
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(2*spread)-spread)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-payments" {
+		os.Exit(verifyPayments(os.Args[2:]))
+	}
+
+	var cfg config
+
+	flag.StringVar(&cfg.membersFile, "members", "./members.json", "Path, URL or git:<dir>@<ref>:<file> of members.json")
+	flag.StringVar(&cfg.servicesFile, "services", "./services.json", "Path, URL or git:<dir>@<ref>:<file> of services.json")
+	flag.StringVar(&cfg.countriesFile, "countries", "./cloudns-countries.json", "Path to the ClouDNS countries file")
+	credentialsFile := flag.String("credentials-file", "", "JSON file with provider credentials per domain, readable by the owner only")
+	credentialsCommand := flag.String("credentials-command", "", "Command printing the credentials, given the provider and domain as $1 and $2 or {provider} and {domain}")
+	flag.StringVar(&cfg.authMode, "auth-mode", geodns.CloudnsSubAuthUser, "ClouDNS authentication mode: auth-id, sub-auth-id or sub-auth-user")
+	rateLimit := flag.Float64("rate-limit", geodns.CloudnsRateLimit, "ClouDNS requests per second, shared by all domains")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of a single ClouDNS request")
+	retries := flag.Int("retries", 4, "Retries of rate limited or transiently failed ClouDNS requests")
+	cacheDir := flag.String("cache", geodns.CacheDir, "Directory caching configuration fetched over HTTP")
+	flag.IntVar(&cfg.ttl, "ttl", 60, "TTL of the geo records")
+	flag.IntVar(&cfg.answers, "answers", 1, "Number of nearest members published per geo location (lowering it needs -prune)")
+	flag.StringVar(&cfg.capacityFile, "capacity", "", "Balance countries by member capacity from this capacity file")
+	flag.StringVar(&cfg.weightsFile, "weights", "", "Balance countries by population or traffic from this country weights file")
+	flag.StringVar(&cfg.latencyFile, "latency", "", "Assign by measured round trip times from this JSON or CSV latency matrix")
+	flag.Float64Var(&cfg.slack, "slack", 0.1, "Fraction a member may exceed its capacity share by")
+	flag.DurationVar(&cfg.probation, "probation", 0, "Time a member has to be at the required level before it serves a service, such as 720h")
+	flag.DurationVar(&cfg.upcoming, "upcoming", 30*24*time.Hour, "Report members starting or stopping to serve a service within this time")
+	flag.Float64Var(&cfg.regionPenalty, "region-penalty", 0, "Latency in ms added to members outside the region of a country, preferring members in its region")
+	flag.BoolVar(&cfg.strictRegions, "strict-regions", false, "Only assign members outside the region of a country when none is in its region")
+	flag.Float64Var(&cfg.hysteresis, "hysteresis", 0, "Latency in ms another member has to win by before a country moves off its current member")
+	flag.BoolVar(&cfg.planOnly, "plan", false, "Print the record changes without applying them")
+	flag.StringVar(&cfg.planFile, "json", "", "Write the record changes as JSON to this file")
+	flag.BoolVar(&cfg.prune, "prune", false, "Delete orphaned and duplicate geo records of the managed hosts")
+	flag.BoolVar(&cfg.health, "health", false, "Probe member endpoints and skip members that are unhealthy")
+	flag.DurationVar(&cfg.healthTimeout, "health-timeout", 10*time.Second, "Timeout of a single endpoint health check")
+	flag.StringVar(&cfg.pruneHosts, "prune-hosts", "", "Comma separated hosts, such as renamed services, whose geo records are all deleted (requires -prune)")
+	daemon := flag.Bool("daemon", false, "Keep running and reconcile on every interval or configuration change")
+	interval := flag.Duration("interval", 5*time.Minute, "Time between reconciliations in daemon mode")
+	metricsAddr := flag.String("metrics", "", "Serve Prometheus metrics on this address, such as :9101")
+	flag.Parse()
+	geodns.CacheDir = *cacheDir
+	if !geodns.ValidCloudnsAuthMode(cfg.authMode) {
+		fmt.Printf("Unknown auth mode %s\n", cfg.authMode)
+		os.Exit(2)
+	}
+	cfg.transport = geodns.NewTransport(*rateLimit, *timeout)
+	cfg.transport.Retries = *retries
+	cfg.credentials = geodns.NewCredentialChain(*credentialsFile, *credentialsCommand)
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", geodns.DefaultMetrics)
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Printf("Failed to serve metrics: %v\n", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if *daemon {
+		os.Exit(runDaemon(cfg, *interval))
+	}
+
+	if err := cfg.sync(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+}
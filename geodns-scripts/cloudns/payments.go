@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+// verifyPayments checks the payment signatures of members.json and returns
+// the exit status, non-zero when any signature is missing or invalid.
+func verifyPayments(args []string) int {
+	flags := flag.NewFlagSet("verify-payments", flag.ExitOnError)
+	membersFile := flags.String("members", "./members.json", "Path, URL or git:<dir>@<ref>:<file> of members.json")
+	flags.Parse(args)
+
+	problems := geodns.ValidatePayments(*membersFile)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problems\n", len(problems))
+		return 1
+	}
+	fmt.Println("All payment signatures are valid")
+	return 0
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+// config holds the command line settings of a sync run.
+type config struct {
+	credentials geodns.CredentialChain
+	authMode    string
+	transport   *geodns.Transport
+
+	membersFile   string
+	servicesFile  string
+	countriesFile string
+	capacityFile  string
+	weightsFile   string
+	latencyFile   string
+
+	ttl           int
+	answers       int
+	slack         float64
+	hysteresis    float64
+	regionPenalty float64
+	strictRegions bool
+	probation     time.Duration
+	upcoming      time.Duration
+	planOnly      bool
+	planFile      string
+	prune         bool
+	pruneHosts    string
+	health        bool
+	healthTimeout time.Duration
+}
+
+// files returns the configuration files read by a sync run.
+func (cfg config) files() []string {
+	var files []string
+	for _, file := range []string{cfg.membersFile, cfg.servicesFile, cfg.countriesFile, cfg.capacityFile, cfg.weightsFile, cfg.latencyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// pruneHostList returns the hosts of -prune-hosts. Hosts still configured
+// as a service in one of the domains they would be pruned from are refused,
+// as pruning would delete every record of that service.
+func (cfg config) pruneHostList(services geodns.Services) ([]string, error) {
+	var domains []string
+	seen := make(map[string]bool)
+	for _, name := range services.Names() {
+		if _, domain := geodns.SplitServiceName(name); !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(cfg.pruneHosts, ",") {
+		// Empty entries, as left by a trailing comma, would be the zone apex
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		for _, domain := range domains {
+			if _, ok := services.Services[host+"."+domain]; ok {
+				return nil, fmt.Errorf("-prune-hosts: %s.%s is still a configured service", host, domain)
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// sync loads the configuration and reconciles the records of every service.
+func (cfg config) sync() error {
+	// Load Member JSON File
+	members, err := geodns.LoadMembers(cfg.membersFile)
+	if err != nil {
+		return err
+	}
+
+	// Load Services JSON File
+	services, err := geodns.LoadServices(cfg.servicesFile)
+	if err != nil {
+		return err
+	}
+
+	// Load Countries JSON File
+	countries, err := geodns.LoadCountries(cfg.countriesFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Loaded countries: %d\n", len(countries.Country))
+
+	var pruneHosts []string
+	if cfg.prune {
+		pruneHosts, err = cfg.pruneHostList(services)
+		if err != nil {
+			return err
+		}
+	}
+
+	assignOpts := geodns.AssignOptions{Answers: cfg.answers, Slack: cfg.slack, RegionPenalty: cfg.regionPenalty, StrictRegions: cfg.strictRegions}
+	if cfg.capacityFile != "" {
+		assignOpts.Capacities, err = geodns.LoadCapacities(cfg.capacityFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Loaded capacities: %d\n", len(assignOpts.Capacities))
+	}
+	if cfg.weightsFile != "" {
+		assignOpts.Weights, err = geodns.LoadCountryWeights(cfg.weightsFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Loaded country weights: %d\n", len(assignOpts.Weights))
+	}
+	if cfg.latencyFile != "" {
+		assignOpts.Latencies, err = geodns.LoadLatencies(cfg.latencyFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Loaded latency measurements for %d countries\n", len(assignOpts.Latencies))
+	}
+
+	type target struct {
+		domain      string
+		host        string
+		recordType  string
+		assignments []geodns.Assignment
+	}
+
+	checker := geodns.NewHealthChecker(cfg.healthTimeout)
+	report := &geodns.Report{}
+
+	// Every domain may use its own credentials
+	providers := make(map[string]geodns.Provider)
+	providerFor := func(domain string) (geodns.Provider, error) {
+		if provider, ok := providers[domain]; ok {
+			return provider, nil
+		}
+		creds, err := cfg.credentials.Resolve("cloudns", domain)
+		if err != nil {
+			return nil, err
+		}
+		cloudns := geodns.NewCloudns(creds.User, creds.Secret)
+		cloudns.AuthMode = cfg.authMode
+		cloudns.Transport = cfg.transport
+		if creds.AuthMode != "" {
+			cloudns.AuthMode = creds.AuthMode
+		}
+		providers[domain] = geodns.InstrumentProvider(cloudns, geodns.DefaultMetrics)
+		return providers[domain], nil
+	}
+
+	var targets []target
+	var domains []string
+	seen := make(map[string]bool)
+	eligibility := geodns.EligibilityOptions{Probation: cfg.probation}
+	for _, name := range services.Names() {
+		service := services.Services[name]
+		host, domain := geodns.SplitServiceName(name)
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+
+		validMembers := service.EligibleMembersAt(members, eligibility)
+		fmt.Printf("Service %s: %d valid members from %d listed (level %d)\n", name, len(validMembers), len(service.Members), service.Level())
+		geodns.PrintUpcomingEligibility(os.Stdout, name, service.UpcomingEligibility(members, eligibility, cfg.upcoming))
+
+		if cfg.health {
+			healthy, results := checker.FilterHealthy(validMembers, service.Chains())
+			geodns.DefaultMetrics.RecordHealth(results)
+			for _, result := range results {
+				if !result.Healthy {
+					fmt.Printf("Service %s: %s %s is unhealthy: %s\n", name, result.Member, result.Endpoint, result.Error)
+				}
+			}
+			fmt.Printf("Service %s: %d of %d valid members are healthy\n", name, len(healthy), len(validMembers))
+			validMembers = healthy
+		}
+
+		// Never prune a service down to nothing because nobody is eligible
+		if len(validMembers) == 0 {
+			geodns.DefaultMetrics.RecordAssignments(name, validMembers, nil)
+			fmt.Printf("Service %s: no valid members, skipping\n", name)
+			report.Add(geodns.Result{Provider: "cloudns", Domain: domain, Host: host, Action: "assign"}, fmt.Errorf("no valid members"))
+			continue
+		}
+
+		// Assign countries to members, separately per record type so that
+		// members without IPv6 only miss out on AAAA records
+		for _, recordType := range geodns.RecordTypes {
+			typeMembers := geodns.MembersWithAddress(validMembers, recordType)
+			if len(typeMembers) == 0 {
+				fmt.Printf("Service %s: no valid members with %s addresses, skipping %s records\n", name, recordType, recordType)
+				continue
+			}
+
+			// Countries only move off their current members when another
+			// member is faster by the hysteresis
+			typeOpts := assignOpts
+			if cfg.hysteresis > 0 {
+				provider, err := providerFor(domain)
+				if err == nil {
+					typeOpts.Current, err = geodns.CurrentMembers(provider, domain, host, recordType, countries.Country, typeMembers)
+				}
+				if err != nil {
+					fmt.Printf("Service %s: reading current %s records: %v\n", name, recordType, err)
+					report.Add(geodns.Result{Provider: "cloudns", Domain: domain, Host: host, Action: "list"}, err)
+					continue
+				}
+				typeOpts.Hysteresis = cfg.hysteresis
+			}
+
+			assignments := geodns.AssignCountries(countries.Country, typeMembers, typeOpts)
+			if recordType == "A" {
+				geodns.DefaultMetrics.RecordAssignments(name, typeMembers, assignments)
+			}
+			geodns.PrintOutOfRegion(os.Stdout, name+" "+recordType, assignments)
+
+			for _, assignment := range assignments {
+				for _, answer := range assignment.Answers() {
+					fmt.Printf("Country: %s assigned to %s - Distance: %f - Latency: %f\n", assignment.Country.Name, answer.Member.Address(recordType), answer.Distance, answer.Latency)
+				}
+			}
+			targets = append(targets, target{domain: domain, host: host, recordType: recordType, assignments: assignments})
+		}
+	}
+
+	// Hosts that are no longer managed keep no records at all
+	for _, domain := range domains {
+		for _, host := range pruneHosts {
+			for _, recordType := range geodns.RecordTypes {
+				targets = append(targets, target{domain: domain, host: host, recordType: recordType})
+			}
+		}
+	}
+
+	var plans []geodns.Plan
+	for _, t := range targets {
+		provider, err := providerFor(t.domain)
+		if err != nil {
+			fmt.Printf("%s.%s: %v\n", t.host, t.domain, err)
+			report.Add(geodns.Result{Provider: "cloudns", Domain: t.domain, Host: t.host, Action: "login"}, err)
+			continue
+		}
+
+		opts := geodns.PlanOptions{TTL: cfg.ttl, Type: t.recordType, Prune: cfg.prune}
+		plan, err := geodns.NewPlan(provider, t.domain, t.host, t.assignments, opts)
+		if err != nil {
+			fmt.Printf("%s.%s: %v\n", t.host, t.domain, err)
+			report.Add(geodns.Result{Provider: provider.Name(), Domain: t.domain, Host: t.host, Action: "list"}, err)
+			continue
+		}
+		plan.Print(os.Stdout)
+		plans = append(plans, plan)
+
+		if cfg.planOnly {
+			continue
+		}
+		if err := geodns.Apply(provider, plan, report); err != nil {
+			fmt.Printf("%s.%s: %v\n", t.host, t.domain, err)
+		}
+	}
+
+	if cfg.planFile != "" {
+		planBytes, err := json.MarshalIndent(plans, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(cfg.planFile, planBytes, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing plan: %v", err)
+		}
+	}
+
+	report.Print(os.Stdout)
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d record changes or steps failed", failed, len(report.Results))
+	}
+
+	if !cfg.planOnly {
+		geodns.DefaultMetrics.SetGauge("geodns_last_successful_sync_timestamp_seconds", "Unix time of the last sync without failures.",
+			nil, float64(time.Now().Unix()))
+	}
+	return nil
+}
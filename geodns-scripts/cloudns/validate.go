@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+// validate checks every configuration file and returns the exit status,
+// non-zero when any problem was found.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	membersFile := flags.String("members", "./members.json", "Path, URL or git:<dir>@<ref>:<file> of members.json")
+	servicesFile := flags.String("services", "./services.json", "Path, URL or git:<dir>@<ref>:<file> of services.json")
+	countriesFiles := flags.String("countries", "./cloudns-countries.json", "Comma separated paths of countries files")
+	flags.Parse(args)
+
+	members, problems := geodns.ValidateMembers(*membersFile)
+	problems = append(problems, geodns.ValidateServices(*servicesFile, members)...)
+	for _, countriesFile := range strings.Split(*countriesFiles, ",") {
+		problems = append(problems, geodns.ValidateCountries(strings.TrimSpace(countriesFile))...)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problems\n", len(problems))
+		return 1
+	}
+	fmt.Println("Configuration is valid")
+	return 0
+}
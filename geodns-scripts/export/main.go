@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+func main() {
+	membersFile := flag.String("members", "../cloudns/members.json", "Path, URL or git:<dir>@<ref>:<file> of members.json")
+	servicesFile := flag.String("services", "../cloudns/services.json", "Path, URL or git:<dir>@<ref>:<file> of services.json")
+	countriesFile := flag.String("countries", "../easydns/easydns-countries.json", "Path to a countries file with one entry per country")
+	format := flag.String("format", "gdnsd", "Output format: gdnsd, powerdns, powerdns-lua or bind")
+	outputDir := flag.String("output", ".", "Directory to write the generated files to")
+	ttl := flag.Int("ttl", 60, "TTL of the generated records")
+	answers := flag.Int("answers", 1, "Number of nearest members answered per country")
+	latencyFile := flag.String("latency", "", "Assign by measured round trip times from this JSON or CSV latency matrix")
+	regionPenalty := flag.Float64("region-penalty", 0, "Latency in ms added to members outside the region of a country, preferring members in its region")
+	strictRegions := flag.Bool("strict-regions", false, "Only assign members outside the region of a country when none is in its region")
+	probation := flag.Duration("probation", 0, "Time a member has to be at the required level before it serves a service, such as 720h")
+	upcoming := flag.Duration("upcoming", 30*24*time.Hour, "Report members starting or stopping to serve a service within this time")
+	flag.Parse()
+
+	// Load Member JSON File
+	members, err := geodns.LoadMembers(*membersFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	// Load Services JSON File
+	services, err := geodns.LoadServices(*servicesFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	// Load Countries JSON File
+	countries, err := geodns.LoadCountries(*countriesFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	assignOpts := geodns.AssignOptions{Answers: *answers, RegionPenalty: *regionPenalty, StrictRegions: *strictRegions}
+	if *latencyFile != "" {
+		assignOpts.Latencies, err = geodns.LoadLatencies(*latencyFile)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var exported []geodns.ExportService
+	eligibility := geodns.EligibilityOptions{Probation: *probation}
+	for _, name := range services.Names() {
+		service := services.Services[name]
+		// Exported zones only hold A records
+		validMembers := geodns.MembersWithAddress(service.EligibleMembersAt(members, eligibility), "A")
+		geodns.PrintUpcomingEligibility(os.Stdout, name, service.UpcomingEligibility(members, eligibility, *upcoming))
+		if len(validMembers) == 0 {
+			fmt.Printf("Service %s: no valid members, skipping\n", name)
+			continue
+		}
+
+		assignments := geodns.AssignCountries(countries.Country, validMembers, assignOpts)
+		geodns.PrintOutOfRegion(os.Stdout, name, assignments)
+		exported = append(exported, geodns.NewExportService(name, validMembers, assignments, *ttl))
+	}
+
+	files, err := geodns.Export(*format, exported)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(2)
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(*outputDir, name)
+		if err := ioutil.WriteFile(path, files[name], 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+}
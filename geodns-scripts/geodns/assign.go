@@ -0,0 +1,373 @@
+package geodns
+
+import (
+	"math"
+	"sort"
+)
+
+// Assignment maps a country to the member serving it.
+type Assignment struct {
+	Country Country
+	Candidate
+
+	// Backups are the next nearest members, nearest first, published next
+	// to Member when a location gets more than one answer.
+	Backups []Candidate
+}
+
+// Candidate is a member considered for a country.
+type Candidate struct {
+	Member   Member
+	Distance float64
+
+	// Latency is the round trip time in ms, measured when Measured is set
+	// and estimated from the distance otherwise.
+	Latency  float64
+	Measured bool
+
+	// score is the latency the candidate is ranked by, lowered by the
+	// hysteresis for members currently serving the country.
+	score float64
+}
+
+// Answers returns the members to publish for the country, nearest first.
+func (a Assignment) Answers() []Candidate {
+	answers := []Candidate{a.Candidate}
+	return append(answers, a.Backups...)
+}
+
+// candidates returns every member for the country, lowest latency first.
+// Members currently serving the country keep it unless another member is
+// faster by more than opts.Hysteresis, and members outside the region of
+// the country rank opts.RegionPenalty behind. With opts.StrictRegions only
+// members in the region are returned, unless there are none.
+func candidates(country Country, members []Member, opts AssignOptions) []Candidate {
+	countryLat, countryLong := country.Coordinates()
+
+	if opts.StrictRegions {
+		var inRegion []Member
+		for _, member := range members {
+			if InRegion(country, member) {
+				inRegion = append(inRegion, member)
+			}
+		}
+		if len(inRegion) > 0 {
+			members = inRegion
+		}
+	}
+
+	current := make(map[string]bool)
+	for _, id := range opts.Current[country.Name] {
+		current[id] = true
+	}
+
+	var candidates []Candidate
+	for _, member := range members {
+		memberLat, memberLong := member.Coordinates()
+		candidate := Candidate{Member: member}
+		candidate.Distance = GetDistance(countryLat, countryLong, memberLat, memberLong)
+		candidate.Latency, candidate.Measured = opts.Latencies.Lookup(country, member)
+		if !candidate.Measured {
+			candidate.Latency = EstimateLatency(candidate.Distance)
+		}
+		candidate.score = candidate.Latency
+		if current[member.ID] {
+			candidate.score -= opts.Hysteresis
+		}
+		if !InRegion(country, member) {
+			candidate.score += opts.RegionPenalty
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].before(candidates[j])
+	})
+	return candidates
+}
+
+// before reports whether the candidate ranks before another one: lower
+// score first, then shorter distance, then lower member ID, so that ties
+// resolve the same way on every run.
+func (c Candidate) before(other Candidate) bool {
+	if c.score != other.score {
+		return c.score < other.score
+	}
+	if c.Distance != other.Distance {
+		return c.Distance < other.Distance
+	}
+	return c.Member.ID < other.Member.ID
+}
+
+// EligibleMembers returns the active members with a location that are at
+// minLevel now, sorted by ID.
+func EligibleMembers(members Members, minLevel int) []Member {
+	return EligibleMembersAt(members, minLevel, EligibilityOptions{})
+}
+
+// EligibleMembersAt returns the members eligible at minLevel at opts.Now
+// after their probation, sorted by ID.
+func EligibleMembersAt(members Members, minLevel int, opts EligibilityOptions) []Member {
+	now := opts.now()
+	var validMembers []Member
+	for _, member := range members.Members {
+		if member.EligibleAt(minLevel, opts.Probation, now) {
+			validMembers = append(validMembers, member)
+		}
+	}
+	sort.Slice(validMembers, func(i, j int) bool {
+		return validMembers[i].ID < validMembers[j].ID
+	})
+	return validMembers
+}
+
+// RecordTypes are the address record types published for every service.
+var RecordTypes = []string{"A", "AAAA"}
+
+// MembersWithAddress returns the members that have a services address for
+// a record type, so that members without IPv6 are only left out of the
+// AAAA assignment.
+func MembersWithAddress(members []Member, recordType string) []Member {
+	var withAddress []Member
+	for _, member := range members {
+		if member.Address(recordType) != "" {
+			withAddress = append(withAddress, member)
+		}
+	}
+	return withAddress
+}
+
+// AssignNearest picks the members with the lowest latency for every country,
+// as many as opts.Answers, so that a single member outage does not take a
+// whole country down. Countries are left out when there is no member to
+// assign.
+func AssignNearest(countries []Country, members []Member, opts AssignOptions) []Assignment {
+	n := opts.Answers
+	if n < 1 {
+		n = 1
+	}
+
+	var assignments []Assignment
+	for _, country := range countries {
+		candidates := candidates(country, members, opts)
+		if len(candidates) == 0 {
+			continue
+		}
+		if len(candidates) > n {
+			candidates = candidates[:n]
+		}
+
+		assignments = append(assignments, Assignment{
+			Country:   country,
+			Candidate: candidates[0],
+			Backups:   candidates[1:],
+		})
+	}
+	return assignments
+}
+
+// AssignOptions selects how countries are assigned to members.
+type AssignOptions struct {
+	// Answers is the number of members published per country.
+	Answers int
+
+	// Capacities holds the relative capacity of members by ID. When set,
+	// countries are balanced so that no member gets more than its share of
+	// the load. Members missing from the map have capacity 1.
+	Capacities map[string]float64
+
+	// Weights holds the share of traffic of countries, such as population or
+	// observed requests, keyed by country name or country code. When set,
+	// members are balanced on the total weight of their countries rather
+	// than the number of countries. Countries without a weight count as 0.
+	Weights map[string]float64
+
+	// Latencies holds measured round trip times. Pairs without a measurement
+	// fall back to an estimate from the great-circle distance.
+	Latencies LatencyMatrix
+
+	// Slack is how far, as a fraction, a member may go over its share before
+	// countries move to a more distant member.
+	Slack float64
+
+	// Current holds the IDs of the members published per country name, as
+	// read from the provider by CurrentMembers.
+	Current map[string][]string
+
+	// Hysteresis is the latency in ms by which another member has to beat
+	// a current member of a country before the country moves to it, so
+	// that small changes do not swing countries back and forth.
+	Hysteresis float64
+
+	// RegionPenalty is the latency in ms added to members outside the
+	// region of a country, so that members in the region are preferred
+	// unless they are slower by more than that.
+	RegionPenalty float64
+
+	// StrictRegions only assigns members outside the region of a country
+	// when no member is in its region.
+	StrictRegions bool
+}
+
+// Weight returns the weight of a country. Countries without weights all
+// weigh 1.
+func (opts AssignOptions) Weight(country Country) float64 {
+	if opts.Weights == nil {
+		return 1
+	}
+	if weight, ok := opts.Weights[country.Name]; ok {
+		return weight
+	}
+	return opts.Weights[country.CC]
+}
+
+// AssignCountries assigns countries to members according to the options.
+func AssignCountries(countries []Country, members []Member, opts AssignOptions) []Assignment {
+	if opts.Capacities == nil && opts.Weights == nil {
+		return AssignNearest(countries, members, opts)
+	}
+	return AssignBalanced(countries, members, opts)
+}
+
+// AssignBalanced assigns every country to the nearest member that still has
+// room for it, where a member's room is its capacity share of the total
+// country weight. Country and member pairs are taken lowest latency first,
+// so capacity only moves a country when a nearer member is full. Countries
+// that fit nowhere go to the member with the most room left. Ties go to the
+// earlier country and the lower member ID. Regions limit the members of a
+// country as they do for AssignNearest. When no member has any capacity
+// there is nothing to balance on and countries go to the nearest members.
+func AssignBalanced(countries []Country, members []Member, opts AssignOptions) []Assignment {
+	if len(members) == 0 {
+		return nil
+	}
+
+	type pair struct {
+		country   int
+		member    int
+		candidate Candidate
+	}
+
+	index := make(map[string]int)
+	for m, member := range members {
+		index[member.ID] = m
+	}
+
+	var pairs []pair
+	countryCandidates := make([][]Candidate, len(countries))
+	for c, country := range countries {
+		countryCandidates[c] = candidates(country, members, opts)
+		for _, candidate := range countryCandidates[c] {
+			pairs = append(pairs, pair{country: c, member: index[candidate.Member.ID], candidate: candidate})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].candidate.score != pairs[j].candidate.score || pairs[i].candidate.Distance != pairs[j].candidate.Distance {
+			return pairs[i].candidate.before(pairs[j].candidate)
+		}
+		if pairs[i].country != pairs[j].country {
+			return pairs[i].country < pairs[j].country
+		}
+		return pairs[i].candidate.Member.ID < pairs[j].candidate.Member.ID
+	})
+
+	totalCapacity := 0.0
+	capacities := make([]float64, len(members))
+	for m, member := range members {
+		capacity, ok := opts.Capacities[member.ID]
+		if !ok {
+			capacity = 1
+		}
+		capacities[m] = capacity
+		totalCapacity += capacity
+	}
+	if totalCapacity <= 0 {
+		return AssignNearest(countries, members, opts)
+	}
+
+	totalLoad := 0.0
+	weights := make([]float64, len(countries))
+	for c, country := range countries {
+		weights[c] = opts.Weight(country)
+		totalLoad += weights[c]
+	}
+
+	limits := make([]float64, len(members))
+	for m := range members {
+		limits[m] = totalLoad * capacities[m] / totalCapacity * (1 + opts.Slack)
+	}
+
+	loads := make([]float64, len(members))
+	primary := make([]int, len(countries))
+	for c := range primary {
+		primary[c] = -1
+	}
+	for _, p := range pairs {
+		if primary[p.country] != -1 || loads[p.member]+weights[p.country] > limits[p.member] {
+			continue
+		}
+		primary[p.country] = p.member
+		loads[p.member] += weights[p.country]
+	}
+	for c := range countries {
+		if primary[c] != -1 {
+			continue
+		}
+		best := -1
+		for _, candidate := range countryCandidates[c] {
+			m := index[candidate.Member.ID]
+			if best == -1 {
+				best = m
+				continue
+			}
+			room, bestRoom := limits[m]-loads[m], limits[best]-loads[best]
+			if room > bestRoom || room == bestRoom && members[m].ID < members[best].ID {
+				best = m
+			}
+		}
+		primary[c] = best
+		loads[best] += weights[c]
+	}
+
+	n := opts.Answers
+	if n < 1 {
+		n = 1
+	}
+
+	var assignments []Assignment
+	for c, country := range countries {
+		var chosen Candidate
+		for _, candidate := range countryCandidates[c] {
+			if candidate.Member.ID == members[primary[c]].ID {
+				chosen = candidate
+				break
+			}
+		}
+
+		assignment := Assignment{Country: country, Candidate: chosen}
+		for _, candidate := range countryCandidates[c] {
+			if len(assignment.Backups) == n-1 {
+				break
+			}
+			if candidate.Member.ID != chosen.Member.ID {
+				assignment.Backups = append(assignment.Backups, candidate)
+			}
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments
+}
+
+// GetDistance returns the great-circle distance in km between two points.
+func GetDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const R = 6371 // Earth's radius in km
+	dLat := (lat2 - lat1) * (math.Pi / 180)
+	dLon := (lon2 - lon1) * (math.Pi / 180)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*(math.Pi/180))*math.Cos(lat2*(math.Pi/180))*
+			math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return R * c
+}
@@ -0,0 +1,257 @@
+package geodns
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func memberAt(id string, lat float64, long float64) Member {
+	return Member{ID: id, ServicesAddress: "192.0.2.1",
+		Lat: strconv.FormatFloat(lat, 'f', -1, 64), Long: strconv.FormatFloat(long, 'f', -1, 64)}
+}
+
+func countryAt(name string, lat float64, long float64) Country {
+	return Country{Name: name, CC: name,
+		Lat: strconv.FormatFloat(lat, 'f', -1, 64), Long: strconv.FormatFloat(long, 'f', -1, 64)}
+}
+
+// assigned formats assignments as "country:member,backup ..." in order.
+func assigned(assignments []Assignment) string {
+	var parts []string
+	for _, assignment := range assignments {
+		var ids []string
+		for _, answer := range assignment.Answers() {
+			ids = append(ids, answer.Member.ID)
+		}
+		parts = append(parts, assignment.Country.Name+":"+strings.Join(ids, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestAssignBalancedCapacity(t *testing.T) {
+	// Every country is nearer to alpha, the first ones the most
+	members := []Member{memberAt("alpha", 0, 0), memberAt("beta", 0, 10)}
+	var countries []Country
+	for i := 0; i < 6; i++ {
+		countries = append(countries, countryAt("C"+strconv.Itoa(i), 0, float64(i)))
+	}
+
+	tests := []struct {
+		name       string
+		capacities map[string]float64
+		slack      float64
+		want       string
+	}{
+		{
+			name:       "equal capacities split the countries",
+			capacities: map[string]float64{},
+			want:       "C0:alpha C1:alpha C2:alpha C3:beta C4:beta C5:beta",
+		},
+		{
+			name:       "slack lets the nearer member go over its share",
+			capacities: map[string]float64{},
+			slack:      0.5,
+			want:       "C0:alpha C1:alpha C2:alpha C3:alpha C4:beta C5:beta",
+		},
+		{
+			name:       "capacities set the shares",
+			capacities: map[string]float64{"alpha": 2, "beta": 1},
+			want:       "C0:alpha C1:alpha C2:alpha C3:alpha C4:beta C5:beta",
+		},
+		{
+			name:       "members missing from the capacities count as 1",
+			capacities: map[string]float64{"beta": 2},
+			want:       "C0:alpha C1:alpha C2:beta C3:beta C4:beta C5:beta",
+		},
+		{
+			name:       "a member without capacity gets nothing",
+			capacities: map[string]float64{"alpha": 0},
+			want:       "C0:beta C1:beta C2:beta C3:beta C4:beta C5:beta",
+		},
+		{
+			name:       "without any capacity countries go to the nearest member",
+			capacities: map[string]float64{"alpha": 0, "beta": 0},
+			want:       "C0:alpha C1:alpha C2:alpha C3:alpha C4:alpha C5:alpha",
+		},
+	}
+
+	for _, test := range tests {
+		opts := AssignOptions{Capacities: test.capacities, Slack: test.slack}
+		if got := assigned(AssignBalanced(countries, members, opts)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAssignBalancedWeights(t *testing.T) {
+	members := []Member{memberAt("alpha", 0, 0), memberAt("beta", 0, 10)}
+	var countries []Country
+	for i := 0; i < 4; i++ {
+		countries = append(countries, countryAt("C"+strconv.Itoa(i), 0, float64(i)))
+	}
+
+	tests := []struct {
+		name       string
+		weights    map[string]float64
+		capacities map[string]float64
+		want       string
+	}{
+		{
+			name:    "a heavy country fills a member",
+			weights: map[string]float64{"C0": 3, "C1": 1, "C2": 1, "C3": 1},
+			want:    "C0:alpha C1:beta C2:beta C3:beta",
+		},
+		{
+			name:    "a country heavier than any share goes to the member with the most room",
+			weights: map[string]float64{"C0": 6, "C1": 1, "C2": 1, "C3": 1},
+			want:    "C0:beta C1:alpha C2:alpha C3:alpha",
+		},
+		{
+			name:       "countries without a weight cost nothing",
+			weights:    map[string]float64{"C3": 4},
+			capacities: map[string]float64{"alpha": 1, "beta": 3},
+			want:       "C0:alpha C1:alpha C2:alpha C3:beta",
+		},
+	}
+
+	for _, test := range tests {
+		opts := AssignOptions{Weights: test.weights, Capacities: test.capacities}
+		if got := assigned(AssignCountries(countries, members, opts)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLoadCapacitiesWeights(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capacity.json")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"capacities": {"alpha": {"weight": 0}, "beta": {"weight": 2.5}, "gamma": {}}}`)
+	capacities, err := LoadCapacities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"alpha": 0, "beta": 2.5}; !reflect.DeepEqual(capacities, want) {
+		t.Errorf("got %v, want %v", capacities, want)
+	}
+
+	write(`{"capacities": {"alpha": {"weight": -1}}}`)
+	if _, err := LoadCapacities(path); err == nil {
+		t.Error("accepted a negative weight")
+	}
+}
+
+func TestEligibleMembersByAddress(t *testing.T) {
+	member := func(v4 string, v6 string) Member {
+		return Member{CurrentLevel: "5", Active: "1", Lat: "50", Long: "8", ServicesAddress: v4, ServicesAddressV6: v6}
+	}
+	members := Members{Members: map[string]Member{
+		"both":  member("192.0.2.1", "2001:db8::1"),
+		"v4":    member("192.0.2.2", ""),
+		"v6":    member("", "2001:db8::3"),
+		"none":  member("", ""),
+		"lower": {CurrentLevel: "2", Active: "1", Lat: "50", Long: "8", ServicesAddress: "192.0.2.5"},
+	}}
+	for id, m := range members.Members {
+		m.ID = id
+		members.Members[id] = m
+	}
+
+	ids := func(members []Member) string {
+		var ids []string
+		for _, member := range members {
+			ids = append(ids, member.ID)
+		}
+		return strings.Join(ids, " ")
+	}
+
+	// Addresses are left to MembersWithAddress, per record type
+	eligible := EligibleMembers(members, 5)
+	if got, want := ids(eligible), "both none v4 v6"; got != want {
+		t.Errorf("eligible %s, want %s", got, want)
+	}
+	if got, want := ids(MembersWithAddress(eligible, "A")), "both v4"; got != want {
+		t.Errorf("A members %s, want %s", got, want)
+	}
+	if got, want := ids(MembersWithAddress(eligible, "AAAA")), "both v6"; got != want {
+		t.Errorf("AAAA members %s, want %s", got, want)
+	}
+}
+
+func TestAssignNearestHysteresis(t *testing.T) {
+	members := []Member{memberAt("alpha", 0, 1), memberAt("beta", 0, 2), memberAt("gamma", 0, 3)}
+	countries := []Country{countryAt("C0", 0, 0)}
+	latencies := LatencyMatrix{"C0": {"alpha": 20, "beta": 15, "gamma": 30}}
+
+	tests := []struct {
+		name       string
+		current    []string
+		hysteresis float64
+		answers    int
+		want       string
+	}{
+		{name: "without hysteresis the fastest member wins", current: []string{"alpha"}, want: "C0:beta"},
+		{name: "the current member keeps the country within the hysteresis", current: []string{"alpha"}, hysteresis: 10, want: "C0:alpha"},
+		{name: "a member faster by more than the hysteresis takes over", current: []string{"alpha"}, hysteresis: 4, want: "C0:beta"},
+		{name: "members that left are not kept", current: []string{"delta"}, hysteresis: 10, want: "C0:beta"},
+		{name: "without current members the fastest member wins", hysteresis: 10, want: "C0:beta"},
+		{name: "every current answer is kept", current: []string{"gamma", "alpha"}, hysteresis: 20, answers: 2, want: "C0:alpha,gamma"},
+		{name: "only current answers within the hysteresis are kept", current: []string{"gamma", "alpha"}, hysteresis: 10, answers: 2, want: "C0:alpha,beta"},
+	}
+
+	for _, test := range tests {
+		opts := AssignOptions{Latencies: latencies, Current: map[string][]string{"C0": test.current}, Hysteresis: test.hysteresis, Answers: test.answers}
+		if got := assigned(AssignNearest(countries, members, opts)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAssignNearestTies(t *testing.T) {
+	tests := []struct {
+		name      string
+		members   []Member
+		latencies LatencyMatrix
+		want      string
+	}{
+		{
+			name:    "equal distances go to the lower ID",
+			members: []Member{memberAt("beta", 0, 10), memberAt("alpha", 0, -10)},
+			want:    "C0:alpha,beta",
+		},
+		{
+			name:    "the order of the members does not matter",
+			members: []Member{memberAt("alpha", 0, -10), memberAt("beta", 0, 10)},
+			want:    "C0:alpha,beta",
+		},
+		{
+			name:      "equal latencies go to the nearer member",
+			members:   []Member{memberAt("alpha", 0, 20), memberAt("beta", 0, 10)},
+			latencies: LatencyMatrix{"C0": {"alpha": 30, "beta": 30}},
+			want:      "C0:beta,alpha",
+		},
+		{
+			name:      "equal latencies and distances go to the lower ID",
+			members:   []Member{memberAt("gamma", 0, 10), memberAt("beta", 10, 0), memberAt("alpha", 0, -10)},
+			latencies: LatencyMatrix{"C0": {"alpha": 30, "beta": 30, "gamma": 30}},
+			want:      "C0:alpha,beta",
+		},
+	}
+
+	countries := []Country{countryAt("C0", 0, 0)}
+	for _, test := range tests {
+		opts := AssignOptions{Latencies: test.latencies, Answers: 2}
+		if got := assigned(AssignNearest(countries, test.members, opts)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
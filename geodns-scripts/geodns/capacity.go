@@ -0,0 +1,123 @@
+package geodns
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// BenchmarkReport is the report written by the benchmark tool.
+type BenchmarkReport struct {
+	Benchmarks struct {
+		CPU    string `json:"cpu"`
+		Memory string `json:"memory"`
+		Disk   string `json:"disk"`
+	} `json:"benchmarks"`
+}
+
+// BenchmarkScore holds the figures parsed from the sysbench output of a
+// benchmark report. Figures that could not be parsed are 0.
+type BenchmarkScore struct {
+	CPUEventsPerSecond float64
+	MemoryMiBPerSecond float64
+	DiskMiBPerSecond   float64
+}
+
+var (
+	cpuEventsRe   = regexp.MustCompile(`events per second:\s*([0-9.]+)`)
+	memoryRateRe  = regexp.MustCompile(`MiB transferred \(([0-9.]+) MiB/sec\)`)
+	diskReadRe    = regexp.MustCompile(`read, MiB/s:\s*([0-9.]+)`)
+	diskWrittenRe = regexp.MustCompile(`written, MiB/s:\s*([0-9.]+)`)
+)
+
+// ParseBenchmarkReport reads a benchmark report and parses its sysbench
+// output.
+func ParseBenchmarkReport(filePath string) (BenchmarkScore, error) {
+	var report BenchmarkReport
+	if err := loadJSON(filePath, &report); err != nil {
+		return BenchmarkScore{}, err
+	}
+
+	score := BenchmarkScore{
+		CPUEventsPerSecond: matchFloat(cpuEventsRe, report.Benchmarks.CPU),
+		MemoryMiBPerSecond: matchFloat(memoryRateRe, report.Benchmarks.Memory),
+		DiskMiBPerSecond:   matchFloat(diskReadRe, report.Benchmarks.Disk) + matchFloat(diskWrittenRe, report.Benchmarks.Disk),
+	}
+	if score.CPUEventsPerSecond == 0 && score.MemoryMiBPerSecond == 0 && score.DiskMiBPerSecond == 0 {
+		return score, fmt.Errorf("no sysbench results found in %s", filePath)
+	}
+	return score, nil
+}
+
+func matchFloat(re *regexp.Regexp, output string) float64 {
+	match := re.FindStringSubmatch(output)
+	if match == nil {
+		return 0
+	}
+	value, _ := strconv.ParseFloat(match[1], 64)
+	return value
+}
+
+// capacityFile is the format of the capacity file, keyed by member ID.
+// A weight overrides the capacity derived from a benchmark report, and a
+// weight of 0 takes the member out of the balancing.
+type capacityFile struct {
+	Capacities map[string]struct {
+		Weight *float64 `json:"weight"`
+		Report string   `json:"report"`
+	} `json:"capacities"`
+}
+
+// LoadCapacities reads a capacity file and returns the relative capacity of
+// every listed member. Benchmark results are normalised against the average
+// of all reports, so a member with average hardware has capacity 1. Report
+// paths are relative to the capacity file, which can be any location
+// accepted by NewSource.
+func LoadCapacities(filePath string) (map[string]float64, error) {
+	var file capacityFile
+	if err := loadJSON(filePath, &file); err != nil {
+		return nil, err
+	}
+
+	capacities := make(map[string]float64)
+	scores := make(map[string]BenchmarkScore)
+	for id, entry := range file.Capacities {
+		switch {
+		case entry.Weight != nil && *entry.Weight < 0:
+			return nil, fmt.Errorf("%s: negative weight for %s", filePath, id)
+		case entry.Weight != nil:
+			capacities[id] = *entry.Weight
+		case entry.Report != "":
+			score, err := ParseBenchmarkReport(resolveSource(filePath, entry.Report))
+			if err != nil {
+				return nil, err
+			}
+			scores[id] = score
+		}
+	}
+
+	var avg BenchmarkScore
+	for _, score := range scores {
+		avg.CPUEventsPerSecond += score.CPUEventsPerSecond / float64(len(scores))
+		avg.MemoryMiBPerSecond += score.MemoryMiBPerSecond / float64(len(scores))
+		avg.DiskMiBPerSecond += score.DiskMiBPerSecond / float64(len(scores))
+	}
+
+	for id, score := range scores {
+		var sum, count float64
+		for _, pair := range [][2]float64{
+			{score.CPUEventsPerSecond, avg.CPUEventsPerSecond},
+			{score.MemoryMiBPerSecond, avg.MemoryMiBPerSecond},
+			{score.DiskMiBPerSecond, avg.DiskMiBPerSecond},
+		} {
+			if pair[0] > 0 && pair[1] > 0 {
+				sum += pair[0] / pair[1]
+				count++
+			}
+		}
+		if count > 0 {
+			capacities[id] = sum / count
+		}
+	}
+	return capacities, nil
+}
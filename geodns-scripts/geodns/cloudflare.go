@@ -0,0 +1,284 @@
+package geodns
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const cloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// CloudflareRateLimit is the default number of Cloudflare requests per
+// second, below the limit of 1200 requests per five minutes.
+const CloudflareRateLimit = 4
+
+// CloudflareRegions are the Cloudflare load balancing regions usable in a
+// countries file next to country codes.
+var CloudflareRegions = []string{"WNAM", "ENAM", "WEU", "EEU", "NSAM", "SSAM", "OC", "ME", "NAF", "SAF", "SAS", "SEAS", "NEAS"}
+
+// Cloudflare talks to the Cloudflare v4 API. Instead of geo records it
+// publishes an assignment as a load balancer with geo steering over one
+// pool per member.
+type Cloudflare struct {
+	APIToken  string
+	AccountID string
+
+	// PoolPrefix names the pools managed by this tool, followed by the
+	// member ID.
+	PoolPrefix string
+
+	// BaseURL defaults to the public Cloudflare API.
+	BaseURL   string
+	Transport *Transport
+}
+
+// CloudflarePool is a load balancer pool. Managed pools hold the services
+// address of a single member.
+type CloudflarePool struct {
+	ID          string             `json:"id,omitempty"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Enabled     bool               `json:"enabled"`
+	Origins     []CloudflareOrigin `json:"origins"`
+}
+
+type CloudflareOrigin struct {
+	Name    string  `json:"name"`
+	Address string  `json:"address"`
+	Enabled bool    `json:"enabled"`
+	Weight  float64 `json:"weight,omitempty"`
+}
+
+// CloudflareLoadBalancer steers the queries of a host to pools by country,
+// then by region, then to the default pools.
+type CloudflareLoadBalancer struct {
+	ID             string              `json:"id,omitempty"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description,omitempty"`
+	TTL            int                 `json:"ttl,omitempty"`
+	Proxied        bool                `json:"proxied"`
+	SteeringPolicy string              `json:"steering_policy"`
+	DefaultPools   []string            `json:"default_pools"`
+	FallbackPool   string              `json:"fallback_pool"`
+	CountryPools   map[string][]string `json:"country_pools"`
+	RegionPools    map[string][]string `json:"region_pools"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+func NewCloudflare(apiToken string, accountID string) *Cloudflare {
+	return &Cloudflare{APIToken: apiToken, AccountID: accountID, PoolPrefix: "ibp-", Transport: NewTransport(CloudflareRateLimit, 30*time.Second)}
+}
+
+func (c *Cloudflare) Name() string {
+	return "cloudflare"
+}
+
+// PoolName returns the name of the pool of a member.
+func (c *Cloudflare) PoolName(member Member) string {
+	return c.PoolPrefix + member.ID
+}
+
+// ListPools returns the managed pools of the account.
+func (c *Cloudflare) ListPools() ([]CloudflarePool, error) {
+	var all []CloudflarePool
+	err := c.list("/accounts/"+c.AccountID+"/load_balancers/pools", func(result json.RawMessage) error {
+		var pools []CloudflarePool
+		if err := json.Unmarshal(result, &pools); err != nil {
+			return err
+		}
+		all = append(all, pools...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var managed []CloudflarePool
+	for _, pool := range all {
+		if strings.HasPrefix(pool.Name, c.PoolPrefix) {
+			managed = append(managed, pool)
+		}
+	}
+	return managed, nil
+}
+
+// CreatePool creates a pool and returns it with its ID.
+func (c *Cloudflare) CreatePool(pool CloudflarePool) (CloudflarePool, error) {
+	result, err := c.do(ActionCreate, "POST", "/accounts/"+c.AccountID+"/load_balancers/pools", pool)
+	if err != nil {
+		return pool, err
+	}
+	var created CloudflarePool
+	if err := json.Unmarshal(result, &created); err != nil {
+		return pool, &ProviderError{Provider: c.Name(), Operation: ActionCreate, Kind: ErrProvider, Message: "invalid response", Err: err}
+	}
+	return created, nil
+}
+
+func (c *Cloudflare) UpdatePool(pool CloudflarePool) error {
+	_, err := c.do(ActionUpdate, "PUT", "/accounts/"+c.AccountID+"/load_balancers/pools/"+pool.ID, pool)
+	return err
+}
+
+func (c *Cloudflare) DeletePool(pool CloudflarePool) error {
+	_, err := c.do(ActionDelete, "DELETE", "/accounts/"+c.AccountID+"/load_balancers/pools/"+pool.ID, nil)
+	return err
+}
+
+// ZoneID looks up the zone of a domain.
+func (c *Cloudflare) ZoneID(domain string) (string, error) {
+	result, err := c.do("list", "GET", "/zones?name="+url.QueryEscape(domain), nil)
+	if err != nil {
+		return "", err
+	}
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(result, &zones); err != nil {
+		return "", &ProviderError{Provider: c.Name(), Operation: "list", Kind: ErrProvider, Message: "invalid response", Err: err}
+	}
+	if len(zones) == 0 {
+		return "", &ProviderError{Provider: c.Name(), Operation: "list", Kind: ErrValidation, Message: "no zone for " + domain}
+	}
+	return zones[0].ID, nil
+}
+
+func (c *Cloudflare) ListLoadBalancers(zoneID string) ([]CloudflareLoadBalancer, error) {
+	var all []CloudflareLoadBalancer
+	err := c.list("/zones/"+zoneID+"/load_balancers", func(result json.RawMessage) error {
+		var lbs []CloudflareLoadBalancer
+		if err := json.Unmarshal(result, &lbs); err != nil {
+			return err
+		}
+		all = append(all, lbs...)
+		return nil
+	})
+	return all, err
+}
+
+func (c *Cloudflare) CreateLoadBalancer(zoneID string, lb CloudflareLoadBalancer) error {
+	_, err := c.do(ActionCreate, "POST", "/zones/"+zoneID+"/load_balancers", lb)
+	return err
+}
+
+func (c *Cloudflare) UpdateLoadBalancer(zoneID string, lb CloudflareLoadBalancer) error {
+	_, err := c.do(ActionUpdate, "PUT", "/zones/"+zoneID+"/load_balancers/"+lb.ID, lb)
+	return err
+}
+
+// list reads every page of a listing.
+func (c *Cloudflare) list(path string, page func(result json.RawMessage) error) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	for number := 1; ; number++ {
+		resp, err := c.request("list", "GET", path+separator+"per_page=50&page="+strconv.Itoa(number), nil)
+		if err != nil {
+			return err
+		}
+		if err := page(resp.Result); err != nil {
+			return &ProviderError{Provider: c.Name(), Operation: "list", Kind: ErrProvider, Message: "invalid response", Err: err}
+		}
+		if resp.ResultInfo.TotalPages <= number {
+			return nil
+		}
+	}
+}
+
+// do sends a request and returns the result of the response envelope.
+func (c *Cloudflare) do(operation string, method string, path string, body interface{}) (json.RawMessage, error) {
+	resp, err := c.request(operation, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
+
+func (c *Cloudflare) request(operation string, method string, path string, body interface{}) (cloudflareResponse, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = cloudflareURL
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return cloudflareResponse{}, &ProviderError{Provider: c.Name(), Operation: operation, Kind: ErrProvider, Err: err}
+		}
+	}
+
+	fail := func(kind error, statusCode int, message string, err error) *ProviderError {
+		return &ProviderError{Provider: c.Name(), Operation: operation, Kind: kind, StatusCode: statusCode, Message: message, Payload: string(payload), Err: err}
+	}
+
+	transport := c.Transport
+	if transport == nil {
+		transport = defaultTransport
+	}
+
+	var parsed cloudflareResponse
+	err := transport.Call(operation != ActionCreate, func(client *http.Client) error {
+		req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return fail(ErrProvider, 0, "", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+c.APIToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fail(ErrNetwork, 0, "", err)
+		}
+		defer resp.Body.Close()
+
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return fail(ErrNetwork, resp.StatusCode, "", err)
+		}
+
+		parsed = cloudflareResponse{}
+		if json.Unmarshal(bodyBytes, &parsed) != nil {
+			if resp.StatusCode != 200 {
+				perr := fail(statusKind(resp.StatusCode), resp.StatusCode, errorMessage(bodyBytes), nil)
+				perr.RetryAfter = retryAfter(resp.Header)
+				return perr
+			}
+			return fail(ErrProvider, resp.StatusCode, "invalid response", nil)
+		}
+		if resp.StatusCode != 200 || !parsed.Success {
+			var messages []string
+			for _, e := range parsed.Errors {
+				messages = append(messages, e.Message)
+			}
+			kind := statusKind(resp.StatusCode)
+			if resp.StatusCode == 200 {
+				kind = ErrValidation
+			}
+			perr := fail(kind, resp.StatusCode, strings.Join(messages, "; "), nil)
+			perr.RetryAfter = retryAfter(resp.Header)
+			return perr
+		}
+		return nil
+	})
+	return parsed, err
+}
@@ -0,0 +1,304 @@
+package geodns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCloudflare serves the pools of one account and the load balancers of
+// one zone from memory, wrapped in v4 API envelopes.
+type fakeCloudflare struct {
+	t        *testing.T
+	pageSize int
+
+	mu            sync.Mutex
+	pools         []CloudflarePool
+	loadBalancers []CloudflareLoadBalancer
+	pages         map[string]int
+	writes        []string
+
+	// lbBody is the last load balancer body written, as sent
+	lbBody map[string]interface{}
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		f.t.Errorf("%s %s: unexpected Authorization %q", r.Method, r.URL, r.Header.Get("Authorization"))
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Method == "POST" || r.Method == "PUT" {
+		f.writes = append(f.writes, r.Method+" "+r.URL.Path)
+	}
+
+	const poolsPath = "/accounts/acc/load_balancers/pools"
+	const lbPath = "/zones/zone1/load_balancers"
+	switch {
+	case r.Method == "GET" && r.URL.Path == poolsPath:
+		f.page(w, r, poolsPath, len(f.pools), func(i int) interface{} { return f.pools[i] })
+
+	case r.Method == "POST" && r.URL.Path == poolsPath:
+		var pool CloudflarePool
+		if err := json.Unmarshal(body, &pool); err != nil || pool.ID != "" {
+			f.t.Errorf("invalid pool to create %s", body)
+		}
+		pool.ID = fmt.Sprintf("pool%d", len(f.pools)+1)
+		f.pools = append(f.pools, pool)
+		f.respond(w, pool)
+
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, poolsPath+"/"):
+		var pool CloudflarePool
+		if err := json.Unmarshal(body, &pool); err != nil {
+			f.t.Errorf("invalid pool to update %s", body)
+		}
+		for i := range f.pools {
+			if f.pools[i].ID == strings.TrimPrefix(r.URL.Path, poolsPath+"/") {
+				f.pools[i] = pool
+			}
+		}
+		f.respond(w, pool)
+
+	case r.Method == "GET" && r.URL.Path == "/zones":
+		if r.URL.Query().Get("name") != "ibp.network" {
+			f.respond(w, []interface{}{})
+			return
+		}
+		f.respond(w, []map[string]string{{"id": "zone1"}})
+
+	case r.Method == "GET" && r.URL.Path == lbPath:
+		f.page(w, r, lbPath, len(f.loadBalancers), func(i int) interface{} { return f.loadBalancers[i] })
+
+	case (r.Method == "POST" && r.URL.Path == lbPath) || (r.Method == "PUT" && strings.HasPrefix(r.URL.Path, lbPath+"/")):
+		var lb CloudflareLoadBalancer
+		if err := json.Unmarshal(body, &lb); err != nil {
+			f.t.Errorf("invalid load balancer %s", body)
+		}
+		f.lbBody = make(map[string]interface{})
+		json.Unmarshal(body, &f.lbBody)
+		if r.Method == "POST" {
+			lb.ID = fmt.Sprintf("lb%d", len(f.loadBalancers)+1)
+			f.loadBalancers = append(f.loadBalancers, lb)
+		} else {
+			for i := range f.loadBalancers {
+				if f.loadBalancers[i].ID == lb.ID {
+					f.loadBalancers[i] = lb
+				}
+			}
+		}
+		f.respond(w, lb)
+
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":7003,"message":"Could not route"}],"result":null}`)
+	}
+}
+
+// page answers a listing with one page of at most pageSize entries and its
+// result_info.
+func (f *fakeCloudflare) page(w http.ResponseWriter, r *http.Request, path string, total int, entry func(int) interface{}) {
+	if r.URL.Query().Get("per_page") == "" {
+		f.t.Errorf("%s listed without per_page", path)
+	}
+	number, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || number < 1 {
+		f.t.Errorf("%s listed with page %q", path, r.URL.Query().Get("page"))
+		number = 1
+	}
+	f.pages[path]++
+
+	result := []interface{}{}
+	for i := (number - 1) * f.pageSize; i < total && i < number*f.pageSize; i++ {
+		result = append(result, entry(i))
+	}
+	totalPages := (total + f.pageSize - 1) / f.pageSize
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true, "errors": []interface{}{}, "result": result,
+		"result_info": map[string]int{"page": number, "per_page": f.pageSize, "count": len(result), "total_count": total, "total_pages": totalPages},
+	})
+}
+
+func (f *fakeCloudflare) respond(w http.ResponseWriter, result interface{}) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "errors": []interface{}{}, "result": result})
+}
+
+func (f *fakeCloudflare) poolID(name string) string {
+	for _, pool := range f.pools {
+		if pool.Name == name {
+			return pool.ID
+		}
+	}
+	return ""
+}
+
+func memberPool(id string, name string, description string, address string) CloudflarePool {
+	return CloudflarePool{ID: id, Name: name, Description: description, Enabled: true, Origins: []CloudflareOrigin{{Name: strings.TrimPrefix(name, "ibp-"), Address: address, Enabled: true, Weight: 1}}}
+}
+
+func TestSamePool(t *testing.T) {
+	desired := memberPool("", "ibp-alpha", "Alpha", "192.0.2.1")
+	tests := []struct {
+		name   string
+		change func(pool *CloudflarePool)
+		want   bool
+	}{
+		{"unchanged but for the ID", func(pool *CloudflarePool) { pool.ID = "old-alpha" }, true},
+		{"renamed member", func(pool *CloudflarePool) { pool.Description = "Alpha Networks" }, false},
+		{"disabled pool", func(pool *CloudflarePool) { pool.Enabled = false }, false},
+		{"disabled origin", func(pool *CloudflarePool) { pool.Origins[0].Enabled = false }, false},
+		{"other origin weight", func(pool *CloudflarePool) { pool.Origins[0].Weight = 0.5 }, false},
+		{"extra origin", func(pool *CloudflarePool) { pool.Origins = append(pool.Origins, pool.Origins[0]) }, false},
+	}
+
+	for _, test := range tests {
+		existing := memberPool("", "ibp-alpha", "Alpha", "192.0.2.1")
+		test.change(&existing)
+		if got := samePool(existing, desired); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCloudflareSteering(t *testing.T) {
+	fake := &fakeCloudflare{t: t, pageSize: 2, pages: make(map[string]int), pools: []CloudflarePool{
+		memberPool("old-alpha", "ibp-alpha", "Alpha", "192.0.2.100"),
+		memberPool("old-beta", "ibp-beta", "Beta", "192.0.2.2"),
+		memberPool("old-gone", "ibp-gone", "Gone", "192.0.2.9"),
+		memberPool("old-delta", "ibp-delta", "Delta", "192.0.2.4"),
+		memberPool("other", "staging-alpha", "Alpha", "192.0.2.1"),
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := NewCloudflare("token", "acc")
+	c.BaseURL = server.URL
+	c.Transport = NewTransport(0, 5*time.Second)
+
+	alpha := Member{ID: "alpha", Name: "Alpha", ServicesAddress: "192.0.2.1"}
+	beta := Member{ID: "beta", Name: "Beta", ServicesAddress: "192.0.2.2"}
+	gamma := Member{ID: "gamma", Name: "Gamma", ServicesAddress: "192.0.2.3"}
+	members := []Member{gamma, alpha, beta}
+
+	// Montenegro and the Middle East region share the key ME
+	assignments := []Assignment{
+		{Country: Country{Name: "Germany", CC: "DE"}, Candidate: Candidate{Member: alpha}},
+		{Country: Country{Name: "Montenegro", CC: "ME", Cloudflare: "ME"}, Candidate: Candidate{Member: beta}},
+		{Country: Country{Name: "Region - Middle East", CC: "SA", CloudflareRegion: "ME"}, Candidate: Candidate{Member: gamma}, Backups: []Candidate{{Member: alpha}}},
+	}
+
+	plan, err := NewSteeringPlan(c, "ibp.network", "rpc", members, assignments, PlanOptions{TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.pages["/accounts/acc/load_balancers/pools"] != 3 {
+		t.Errorf("listed %d pool pages, want 3", fake.pages["/accounts/acc/load_balancers/pools"])
+	}
+	if plan.LoadBalancer != ActionCreate {
+		t.Errorf("load balancer %q, want create", plan.LoadBalancer)
+	}
+	var poolChanges []string
+	for _, change := range plan.Pools {
+		poolChanges = append(poolChanges, change.Action+" "+change.Pool+" "+change.OldAddress+" "+change.NewAddress)
+	}
+	if got, want := strings.Join(poolChanges, "; "), "update ibp-alpha 192.0.2.100 192.0.2.1; create ibp-gamma  192.0.2.3"; got != want {
+		t.Errorf("pool changes %q, want %q", got, want)
+	}
+	if len(plan.Steering) != 3 {
+		t.Errorf("got %d steering changes, want 3", len(plan.Steering))
+	}
+
+	report := &Report{}
+	if err := ApplySteering(c, plan, report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed() != 0 {
+		t.Errorf("%d failed writes", report.Failed())
+	}
+	wantWrites := []string{
+		"PUT /accounts/acc/load_balancers/pools/old-alpha",
+		"POST /accounts/acc/load_balancers/pools",
+		"POST /zones/zone1/load_balancers",
+	}
+	if !reflect.DeepEqual(fake.writes, wantWrites) {
+		t.Errorf("writes %v, want %v", fake.writes, wantWrites)
+	}
+	if pool := fake.pools[0]; pool.ID != "old-alpha" || pool.Origins[0].Address != "192.0.2.1" {
+		t.Errorf("alpha pool not updated: %+v", pool)
+	}
+
+	gammaID := fake.poolID("ibp-gamma")
+	want := map[string]interface{}{
+		"name":            "rpc.ibp.network",
+		"ttl":             float64(60),
+		"proxied":         false,
+		"steering_policy": "geo",
+		"default_pools":   []interface{}{"old-alpha", "old-beta", gammaID},
+		"fallback_pool":   "old-alpha",
+		"country_pools": map[string]interface{}{
+			"DE": []interface{}{"old-alpha"},
+			"ME": []interface{}{"old-beta"},
+		},
+		"region_pools": map[string]interface{}{
+			"ME": []interface{}{gammaID, "old-alpha"},
+		},
+	}
+	if !reflect.DeepEqual(fake.lbBody, want) {
+		got, _ := json.MarshalIndent(fake.lbBody, "", "  ")
+		t.Errorf("load balancer body\n%s", got)
+	}
+
+	// Once applied there is nothing left to change
+	plan, err = NewSteeringPlan(c, "ibp.network", "rpc", members, assignments, PlanOptions{TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.LoadBalancer != "" || len(plan.Pools) != 0 || len(plan.Steering) != 0 {
+		t.Errorf("second plan not empty: %+v", plan)
+	}
+
+	// Moving Germany to beta only updates the load balancer
+	assignments[0].Candidate = Candidate{Member: beta}
+	plan, err = NewSteeringPlan(c, "ibp.network", "rpc", members, assignments, PlanOptions{TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.LoadBalancer != ActionUpdate || len(plan.Steering) != 1 || plan.Steering[0].Key != "DE" {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	if err := ApplySteering(c, plan, report); err != nil {
+		t.Fatal(err)
+	}
+	if last := fake.writes[len(fake.writes)-1]; last != "PUT /zones/zone1/load_balancers/lb1" {
+		t.Errorf("last write %s, want the load balancer update", last)
+	}
+	if pools := fake.lbBody["country_pools"].(map[string]interface{})["DE"]; !reflect.DeepEqual(pools, []interface{}{"old-beta"}) {
+		t.Errorf("DE steered to %v", pools)
+	}
+
+	// Pools of members no service steers to are unused, pools not managed
+	// by the tool are left alone
+	unused, err := UnusedPools(c, []Member{alpha, beta, gamma})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pool := range unused {
+		names = append(names, pool.Name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "ibp-delta ibp-gone" {
+		t.Errorf("unused pools %s, want ibp-delta ibp-gone", got)
+	}
+}
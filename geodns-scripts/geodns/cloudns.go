@@ -0,0 +1,263 @@
+package geodns
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cloudnsURL = "https://api.cloudns.net"
+
+// ClouDNS authentication modes, named after the parameter carrying the user:
+// the main account ID, or a sub user by ID or by name.
+const (
+	CloudnsAuthID      = "auth-id"
+	CloudnsSubAuthID   = "sub-auth-id"
+	CloudnsSubAuthUser = "sub-auth-user"
+)
+
+// Cloudns talks to the ClouDNS HTTP API.
+type Cloudns struct {
+	AuthUser string
+	Password string
+
+	// AuthMode is one of the ClouDNS authentication modes and defaults to
+	// CloudnsSubAuthUser.
+	AuthMode string
+
+	// BaseURL defaults to the public ClouDNS API.
+	BaseURL   string
+	Transport *Transport
+
+	loginMu  sync.Mutex
+	loggedIn bool
+	loginErr error
+}
+
+type cloudnsRecord struct {
+	ID       string `json:"id"`
+	Host     string `json:"host"`
+	TTL      string `json:"ttl"`
+	Type     string `json:"type"`
+	Record   string `json:"record"`
+	GeodnsId string `json:"geodns-location"`
+}
+
+// CloudnsRateLimit is the default number of ClouDNS requests per second.
+const CloudnsRateLimit = 10
+
+func NewCloudns(authUser string, password string) *Cloudns {
+	return &Cloudns{AuthUser: authUser, Password: password, Transport: NewTransport(CloudnsRateLimit, 30*time.Second)}
+}
+
+// ValidCloudnsAuthMode reports whether mode is a known authentication mode.
+func ValidCloudnsAuthMode(mode string) bool {
+	switch mode {
+	case CloudnsAuthID, CloudnsSubAuthID, CloudnsSubAuthUser:
+		return true
+	}
+	return false
+}
+
+func (c *Cloudns) Name() string {
+	return "cloudns"
+}
+
+func (c *Cloudns) LocationID(country Country) int {
+	return country.GeodnsId
+}
+
+func (c *Cloudns) ListRecords(domain string) ([]Record, error) {
+	data := url.Values{}
+	data.Set("domain-name", domain)
+
+	bodyBytes, err := c.post("list", "/dns/records.json", data)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty zone is returned as an empty list instead of an object.
+	if strings.TrimSpace(string(bodyBytes)) == "[]" {
+		return nil, nil
+	}
+
+	var recordsMap map[string]cloudnsRecord
+	if err := json.Unmarshal(bodyBytes, &recordsMap); err != nil {
+		return nil, &ProviderError{Provider: c.Name(), Operation: "list", Kind: ErrProvider, Message: "invalid response", Err: err}
+	}
+
+	var records []Record
+	for _, record := range recordsMap {
+		ttl, _ := strconv.Atoi(record.TTL)
+		location, _ := strconv.Atoi(record.GeodnsId)
+		records = append(records, Record{
+			ID:       record.ID,
+			Host:     record.Host,
+			Type:     record.Type,
+			TTL:      ttl,
+			Value:    record.Record,
+			Location: location,
+		})
+	}
+	return records, nil
+}
+
+// Login checks the credentials against /dns/login.json.
+func (c *Cloudns) Login() error {
+	_, err := c.post("login", "/dns/login.json", url.Values{})
+	return err
+}
+
+// checkLogin logs in once before the first write, so bad credentials fail
+// the run up front instead of every record change on its own. Only rejected
+// credentials are remembered, other failures are tried again.
+func (c *Cloudns) checkLogin() error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	if c.loggedIn || c.loginErr != nil {
+		return c.loginErr
+	}
+
+	err := c.Login()
+	if err == nil {
+		c.loggedIn = true
+	} else if errors.Is(err, ErrAuth) {
+		c.loginErr = err
+	}
+	return err
+}
+
+func (c *Cloudns) CreateRecord(domain string, record Record) error {
+	if err := c.checkLogin(); err != nil {
+		return err
+	}
+
+	data := url.Values{}
+	data.Set("domain-name", domain)
+	data.Set("record-type", record.Type)
+	data.Set("host", record.Host)
+	data.Set("record", record.Value)
+	data.Set("ttl", strconv.Itoa(record.TTL))
+	data.Set("geodns-location", strconv.Itoa(record.Location))
+
+	_, err := c.post(ActionCreate, "/dns/add-record.json", data)
+	return err
+}
+
+func (c *Cloudns) UpdateRecord(domain string, record Record) error {
+	if err := c.checkLogin(); err != nil {
+		return err
+	}
+
+	data := url.Values{}
+	data.Set("domain-name", domain)
+	data.Set("record-id", record.ID)
+	data.Set("host", record.Host)
+	data.Set("record", record.Value)
+	data.Set("ttl", strconv.Itoa(record.TTL))
+	data.Set("geodns-location", strconv.Itoa(record.Location))
+
+	_, err := c.post(ActionUpdate, "/dns/mod-record.json", data)
+	return err
+}
+
+func (c *Cloudns) DeleteRecord(domain string, record Record) error {
+	if err := c.checkLogin(); err != nil {
+		return err
+	}
+
+	data := url.Values{}
+	data.Set("domain-name", domain)
+	data.Set("record-id", record.ID)
+
+	_, err := c.post(ActionDelete, "/dns/delete-record.json", data)
+	return err
+}
+
+type cloudnsStatus struct {
+	Status            string `json:"status"`
+	StatusDescription string `json:"statusDescription"`
+}
+
+// post sends an authenticated form request and returns the response body.
+// ClouDNS reports most failures with HTTP 200 and a "Failed" status, which
+// are turned into errors as well.
+func (c *Cloudns) post(operation string, path string, data url.Values) ([]byte, error) {
+	payload := data.Encode()
+
+	fail := func(kind error, statusCode int, message string, err error) *ProviderError {
+		return &ProviderError{Provider: c.Name(), Operation: operation, Kind: kind, StatusCode: statusCode, Message: message, Payload: payload, Err: err}
+	}
+
+	mode := c.AuthMode
+	if mode == "" {
+		mode = CloudnsSubAuthUser
+	}
+	if !ValidCloudnsAuthMode(mode) {
+		return nil, fail(ErrAuth, 0, "unknown auth mode "+mode, nil)
+	}
+	data.Set(mode, c.AuthUser)
+	data.Set("auth-password", c.Password)
+
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = cloudnsURL
+	}
+
+	body := data.Encode()
+
+	transport := c.Transport
+	if transport == nil {
+		transport = defaultTransport
+	}
+
+	// Every call but adding a record can safely be repeated
+	var bodyBytes []byte
+	err := transport.Call(operation != ActionCreate, func(client *http.Client) error {
+		req, err := http.NewRequest("POST", baseURL+path, strings.NewReader(body))
+		if err != nil {
+			return fail(ErrProvider, 0, "", err)
+		}
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fail(ErrNetwork, 0, "", err)
+		}
+		defer resp.Body.Close()
+
+		bodyBytes, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return fail(ErrNetwork, resp.StatusCode, "", err)
+		}
+		if resp.StatusCode != 200 {
+			perr := fail(statusKind(resp.StatusCode), resp.StatusCode, errorMessage(bodyBytes), nil)
+			perr.RetryAfter = retryAfter(resp.Header)
+			return perr
+		}
+
+		var status cloudnsStatus
+		if json.Unmarshal(bodyBytes, &status) == nil && strings.EqualFold(status.Status, "Failed") {
+			description := strings.ToLower(status.StatusDescription)
+			kind := ErrValidation
+			switch {
+			case strings.Contains(description, "auth"):
+				kind = ErrAuth
+			case strings.Contains(description, "limit"), strings.Contains(description, "too many"):
+				kind = ErrRateLimit
+			}
+			return fail(kind, resp.StatusCode, status.StatusDescription, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bodyBytes, nil
+}
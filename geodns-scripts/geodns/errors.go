@@ -0,0 +1,110 @@
+package geodns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kinds of provider errors, matched with errors.Is.
+var (
+	ErrAuth       = errors.New("authentication failed")
+	ErrRateLimit  = errors.New("rate limited")
+	ErrValidation = errors.New("rejected by provider")
+	ErrNetwork    = errors.New("network error")
+	ErrProvider   = errors.New("provider error")
+)
+
+// ProviderError is returned by every provider call that failed.
+type ProviderError struct {
+	Provider  string
+	Operation string
+
+	// Kind is one of the Err* values above.
+	Kind error
+
+	// StatusCode is the HTTP status, 0 when no response was received.
+	StatusCode int
+	Message    string
+
+	// Payload is the request that was rejected, without credentials.
+	Payload string
+
+	// Err is the underlying error, if any.
+	Err error
+
+	// Attempts is the number of times the call was made, RetryAfter the
+	// delay the provider asked for before the next one.
+	Attempts   int
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	msg := fmt.Sprintf("%s %s: %v", e.Provider, e.Operation, e.Kind)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (HTTP %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" after %d attempts", e.Attempts)
+	}
+	if e.Payload != "" {
+		msg += " (payload: " + e.Payload + ")"
+	}
+	return Redact(msg)
+}
+
+func (e *ProviderError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// statusKind classifies an HTTP status code.
+func statusKind(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrAuth
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimit
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusNotFound ||
+		statusCode == http.StatusConflict || statusCode == http.StatusUnprocessableEntity:
+		return ErrValidation
+	default:
+		return ErrProvider
+	}
+}
+
+// errorMessage extracts a readable message from an error response body.
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		StatusDescription string `json:"statusDescription"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		if parsed.Error.Message != "" {
+			return parsed.Error.Message
+		}
+		if parsed.StatusDescription != "" {
+			return parsed.StatusDescription
+		}
+	}
+
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	return msg
+}
@@ -0,0 +1,327 @@
+package geodns
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExportService is the country to member map of a service, the input for
+// the configuration of self hosted GeoDNS servers.
+type ExportService struct {
+	Name   string
+	Host   string
+	Domain string
+	TTL    int
+
+	// Members are the eligible members sorted by ID, answered for
+	// countries without an assignment.
+	Members []Member
+
+	// Countries maps country codes to their answers, nearest first.
+	Countries map[string][]Member
+}
+
+// ExportFormats names the supported export formats.
+var ExportFormats = []string{"gdnsd", "powerdns", "powerdns-lua", "bind"}
+
+// NewExportService collects the assignments of a service by country code.
+// Self hosted servers only tell countries apart, so entries of a country
+// such as "United States - Region I" are only used when the file has no
+// entry for the whole country.
+func NewExportService(name string, members []Member, assignments []Assignment, ttl int) ExportService {
+	host, domain := SplitServiceName(name)
+	service := ExportService{Name: name, Host: host, Domain: domain, TTL: ttl, Countries: make(map[string][]Member)}
+
+	service.Members = append(service.Members, members...)
+	sort.Slice(service.Members, func(i, j int) bool {
+		return service.Members[i].ID < service.Members[j].ID
+	})
+
+	for _, regions := range []bool{false, true} {
+		for _, assignment := range assignments {
+			cc := strings.ToUpper(assignment.Country.CC)
+			if strings.Contains(assignment.Country.Name, " - ") != regions || cc == "" {
+				continue
+			}
+			if _, ok := service.Countries[cc]; ok {
+				continue
+			}
+			for _, answer := range assignment.Answers() {
+				service.Countries[cc] = append(service.Countries[cc], answer.Member)
+			}
+		}
+	}
+	return service
+}
+
+// Export renders the services in a format and returns the files to write.
+func Export(format string, services []ExportService) (map[string][]byte, error) {
+	sorted := append([]ExportService{}, services...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	switch format {
+	case "gdnsd":
+		return exportGdnsd(sorted), nil
+	case "powerdns":
+		return exportPowerDNS(sorted), nil
+	case "powerdns-lua":
+		return exportPowerDNSLua(sorted), nil
+	case "bind":
+		return exportBind(sorted), nil
+	}
+	return nil, fmt.Errorf("unknown export format %s, use one of %s", format, strings.Join(ExportFormats, ", "))
+}
+
+func (s ExportService) codes() []string {
+	var codes []string
+	for cc := range s.Countries {
+		codes = append(codes, cc)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func memberIDs(members []Member) []string {
+	var ids []string
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+	return ids
+}
+
+func memberAddresses(members []Member) []string {
+	var addresses []string
+	for _, member := range members {
+		addresses = append(addresses, member.ServicesAddress)
+	}
+	return addresses
+}
+
+// exportName turns a service name into an identifier usable in every
+// format.
+func exportName(name string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
+
+const exportHeader = "Generated by geodns-manager, do not edit."
+
+// exportGdnsd writes a geoip plugin config with a map and a resource per
+// service, with members as datacenters. The map skips the continent level
+// so countries are keyed directly.
+func exportGdnsd(services []ExportService) map[string][]byte {
+	var config, zones bytes.Buffer
+	fmt.Fprintf(&config, "# %s\nplugins => {\n  geoip => {\n    maps => {\n", exportHeader)
+	for _, service := range services {
+		fmt.Fprintf(&config, "      %s => {\n", exportName(service.Name))
+		fmt.Fprintf(&config, "        geoip2_db => GeoLite2-Country.mmdb\n")
+		fmt.Fprintf(&config, "        datacenters => [%s]\n", strings.Join(memberIDs(service.Members), ", "))
+		fmt.Fprintf(&config, "        skip_level => 1\n")
+		fmt.Fprintf(&config, "        map => {\n")
+		for _, cc := range service.codes() {
+			fmt.Fprintf(&config, "          %s => [%s]\n", cc, strings.Join(memberIDs(service.Countries[cc]), ", "))
+		}
+		fmt.Fprintf(&config, "        }\n      }\n")
+	}
+	fmt.Fprintf(&config, "    }\n    resources => {\n")
+	for _, service := range services {
+		fmt.Fprintf(&config, "      %s => {\n", exportName(service.Name))
+		fmt.Fprintf(&config, "        map => %s\n", exportName(service.Name))
+		fmt.Fprintf(&config, "        dcmap => {\n")
+		for _, member := range service.Members {
+			fmt.Fprintf(&config, "          %s => %s\n", member.ID, member.ServicesAddress)
+		}
+		fmt.Fprintf(&config, "        }\n      }\n")
+	}
+	fmt.Fprintf(&config, "    }\n  }\n}\n")
+
+	fmt.Fprintf(&zones, "; %s\n; Records to add to the zones served by gdnsd.\n", exportHeader)
+	for _, service := range services {
+		fmt.Fprintf(&zones, "; %s\n%s %d DYNA geoip!%s\n", service.Domain, exportHost(service.Host), service.TTL, exportName(service.Name))
+	}
+
+	return map[string][]byte{"gdnsd-geoip.conf": config.Bytes(), "gdnsd-records.zone": zones.Bytes()}
+}
+
+// exportPowerDNS writes a geoip backend zone file. Every country gets
+// records under its code, which the service template picks by the country
+// of the client, falling back to the default records.
+func exportPowerDNS(services []ExportService) map[string][]byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# %s\ndomains:\n", exportHeader)
+
+	var domains []string
+	byDomain := make(map[string][]ExportService)
+	for _, service := range services {
+		if _, ok := byDomain[service.Domain]; !ok {
+			domains = append(domains, service.Domain)
+		}
+		byDomain[service.Domain] = append(byDomain[service.Domain], service)
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		fmt.Fprintf(&out, "- domain: %s\n", domain)
+		fmt.Fprintf(&out, "  records:\n")
+		for _, service := range byDomain[domain] {
+			fmt.Fprintf(&out, "    default.%s:\n", service.Name)
+			for _, address := range memberAddresses(service.Members) {
+				fmt.Fprintf(&out, "      - a: {content: %s, ttl: %d}\n", address, service.TTL)
+			}
+			for _, cc := range service.codes() {
+				fmt.Fprintf(&out, "    %s.%s:\n", strings.ToLower(cc), service.Name)
+				for _, address := range memberAddresses(service.Countries[cc]) {
+					fmt.Fprintf(&out, "      - a: {content: %s, ttl: %d}\n", address, service.TTL)
+				}
+			}
+		}
+		fmt.Fprintf(&out, "  services:\n")
+		for _, service := range byDomain[domain] {
+			fmt.Fprintf(&out, "    %s: ['%%cc.%s', 'default.%s']\n", service.Name, service.Name, service.Name)
+		}
+	}
+	return map[string][]byte{"powerdns-geoip.yaml": out.Bytes()}
+}
+
+// exportPowerDNSLua writes LUA records that look up the answers by the
+// country of the client.
+func exportPowerDNSLua(services []ExportService) map[string][]byte {
+	files := make(map[string][]byte)
+	for _, service := range services {
+		file := "powerdns-lua." + service.Domain + ".zone"
+		out := bytes.NewBuffer(files[file])
+		if out.Len() == 0 {
+			fmt.Fprintf(out, "; %s\n; LUA records to add to the %s zone.\n", exportHeader, service.Domain)
+		}
+
+		fmt.Fprintf(out, "%s %d IN LUA A ( \";local answers = {\"\n", exportHost(service.Host), service.TTL)
+		for _, cc := range service.codes() {
+			fmt.Fprintf(out, "  \"%s={%s},\"\n", cc, luaList(memberAddresses(service.Countries[cc])))
+		}
+		fmt.Fprintf(out, "  \"} return answers[countryCode()] or {%s}\" )\n", luaList(memberAddresses(service.Members)))
+		files[file] = out.Bytes()
+	}
+	return files
+}
+
+func luaList(values []string) string {
+	var quoted []string
+	for _, value := range values {
+		quoted = append(quoted, "'"+value+"'")
+	}
+	return strings.Join(quoted, ",")
+}
+
+// exportBind writes one view per set of countries getting the same answers
+// for every service, plus a default view. Each view includes a fragment
+// with the records of its zones; the zone files with SOA and NS records are
+// left to the operator and are expected to $INCLUDE the fragment.
+func exportBind(services []ExportService) map[string][]byte {
+	var domains []string
+	seen := make(map[string]bool)
+	codes := make(map[string]bool)
+	for _, service := range services {
+		if !seen[service.Domain] {
+			seen[service.Domain] = true
+			domains = append(domains, service.Domain)
+		}
+		for cc := range service.Countries {
+			codes[cc] = true
+		}
+	}
+	sort.Strings(domains)
+
+	// Group the countries by their answers across all services
+	answers := func(cc string) []string {
+		var key []string
+		for _, service := range services {
+			members, ok := service.Countries[cc]
+			if !ok {
+				members = service.Members
+			}
+			key = append(key, service.Name+"="+strings.Join(memberAddresses(members), ","))
+		}
+		return key
+	}
+	var sortedCodes []string
+	for cc := range codes {
+		sortedCodes = append(sortedCodes, cc)
+	}
+	sort.Strings(sortedCodes)
+
+	type view struct {
+		name      string
+		countries []string
+		answers   map[string][]string
+	}
+	var views []*view
+	byKey := make(map[string]*view)
+	for _, cc := range sortedCodes {
+		key := strings.Join(answers(cc), ";")
+		v, ok := byKey[key]
+		if !ok {
+			v = &view{name: "geo_" + strings.ToLower(cc), answers: make(map[string][]string)}
+			for _, service := range services {
+				members, ok := service.Countries[cc]
+				if !ok {
+					members = service.Members
+				}
+				v.answers[service.Name] = memberAddresses(members)
+			}
+			byKey[key] = v
+			views = append(views, v)
+		}
+		v.countries = append(v.countries, cc)
+	}
+
+	fallback := &view{name: "geo_default", answers: make(map[string][]string)}
+	for _, service := range services {
+		fallback.answers[service.Name] = memberAddresses(service.Members)
+	}
+	views = append(views, fallback)
+
+	files := make(map[string][]byte)
+	var conf bytes.Buffer
+	fmt.Fprintf(&conf, "// %s\n", exportHeader)
+	for _, v := range views {
+		fmt.Fprintf(&conf, "\nview \"%s\" {\n", v.name)
+		if len(v.countries) == 0 {
+			fmt.Fprintf(&conf, "  match-clients { any; };\n")
+		} else {
+			fmt.Fprintf(&conf, "  match-clients {\n")
+			for _, cc := range v.countries {
+				fmt.Fprintf(&conf, "    geoip country %s;\n", cc)
+			}
+			fmt.Fprintf(&conf, "  };\n")
+		}
+		for _, domain := range domains {
+			fmt.Fprintf(&conf, "  zone \"%s\" {\n    type primary;\n    file \"db.%s.%s\";\n  };\n", domain, domain, v.name)
+
+			var records bytes.Buffer
+			fmt.Fprintf(&records, "; %s\n; $INCLUDE this file from db.%s.%s\n", exportHeader, domain, v.name)
+			for _, service := range services {
+				if service.Domain != domain {
+					continue
+				}
+				for _, address := range v.answers[service.Name] {
+					fmt.Fprintf(&records, "%s %d IN A %s\n", exportHost(service.Host), service.TTL, address)
+				}
+			}
+			files["db."+domain+"."+v.name+".geo"] = records.Bytes()
+		}
+		fmt.Fprintf(&conf, "};\n")
+	}
+	files["named.conf.geo-views"] = conf.Bytes()
+	return files
+}
+
+// exportHost returns the owner name of a host in a zone file.
+func exportHost(host string) string {
+	if host == "" {
+		return "@"
+	}
+	return host
+}
@@ -0,0 +1,168 @@
+package geodns
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthChecker probes member RPC endpoints over WebSocket with a JSON-RPC
+// system_health call.
+type HealthChecker struct {
+	Timeout time.Duration
+
+	// TLSConfig is used for wss endpoints, mostly to trust test servers.
+	TLSConfig *tls.Config
+}
+
+// HealthResult is the outcome of probing one endpoint of a member.
+type HealthResult struct {
+	Member    string `json:"member"`
+	Chain     string `json:"chain"`
+	Endpoint  string `json:"endpoint"`
+	Healthy   bool   `json:"healthy"`
+	Peers     int    `json:"peers"`
+	IsSyncing bool   `json:"is_syncing"`
+	Error     string `json:"error,omitempty"`
+}
+
+type systemHealth struct {
+	Peers           int  `json:"peers"`
+	IsSyncing       bool `json:"isSyncing"`
+	ShouldHavePeers bool `json:"shouldHavePeers"`
+}
+
+type rpcResponse struct {
+	Result *systemHealth `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{Timeout: timeout}
+}
+
+// CheckEndpoint calls system_health on a single endpoint. A node is healthy
+// when it is not syncing and has peers if it should have any.
+func (h *HealthChecker) CheckEndpoint(endpoint string) HealthResult {
+	result := HealthResult{Endpoint: endpoint}
+
+	health, err := h.systemHealth(endpoint)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Peers = health.Peers
+	result.IsSyncing = health.IsSyncing
+	switch {
+	case health.IsSyncing:
+		result.Error = "node is syncing"
+	case health.ShouldHavePeers && health.Peers == 0:
+		result.Error = "node has no peers"
+	default:
+		result.Healthy = true
+	}
+	return result
+}
+
+func (h *HealthChecker) systemHealth(endpoint string) (systemHealth, error) {
+	var health systemHealth
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	ws, err := dialWebsocket(endpoint, timeout, h.TLSConfig)
+	if err != nil {
+		return health, err
+	}
+	defer ws.Close()
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"system_health","params":[]}`
+	if err := ws.WriteText([]byte(request)); err != nil {
+		return health, err
+	}
+
+	message, err := ws.ReadText()
+	if err != nil {
+		return health, err
+	}
+
+	var resp rpcResponse
+	if err := json.Unmarshal(message, &resp); err != nil {
+		return health, fmt.Errorf("invalid system_health response: %v", err)
+	}
+	if resp.Error != nil {
+		return health, fmt.Errorf("system_health failed: %s", resp.Error.Message)
+	}
+	if resp.Result == nil {
+		return health, fmt.Errorf("empty system_health response")
+	}
+	return *resp.Result, nil
+}
+
+// CheckMembers probes the endpoints of every member for the given chains in
+// parallel. Members without an endpoint for a chain are not probed for it.
+func (h *HealthChecker) CheckMembers(members []Member, chains []string) []HealthResult {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var results []HealthResult
+
+	for _, member := range members {
+		for _, chain := range chains {
+			endpoint, ok := member.Endpoints[chain]
+			if !ok || endpoint == "" {
+				continue
+			}
+
+			wg.Add(1)
+			go func(member string, chain string, endpoint string) {
+				defer wg.Done()
+				result := h.CheckEndpoint(endpoint)
+				result.Member = member
+				result.Chain = chain
+
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}(member.ID, chain, endpoint)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Member != results[j].Member {
+			return results[i].Member < results[j].Member
+		}
+		return results[i].Chain < results[j].Chain
+	})
+	return results
+}
+
+// FilterHealthy drops members with at least one unhealthy endpoint among the
+// chains. Members that list no endpoints can't be probed and are kept.
+func (h *HealthChecker) FilterHealthy(members []Member, chains []string) ([]Member, []HealthResult) {
+	results := h.CheckMembers(members, chains)
+
+	unhealthy := make(map[string]bool)
+	for _, result := range results {
+		if !result.Healthy {
+			unhealthy[result.Member] = true
+		}
+	}
+
+	var healthy []Member
+	for _, member := range members {
+		if !unhealthy[member.ID] {
+			healthy = append(healthy, member)
+		}
+	}
+	return healthy, results
+}
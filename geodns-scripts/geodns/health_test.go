@@ -0,0 +1,205 @@
+package geodns
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// healthStub is a WebSocket server that answers one system_health request
+// with a canned JSON-RPC response.
+type healthStub struct {
+	response  string
+	badAccept bool
+	hang      bool
+}
+
+func (s healthStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	if s.badAccept {
+		accept = base64.StdEncoding.EncodeToString(make([]byte, 20))
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	rw.Flush()
+
+	request, err := readClientFrame(rw.Reader)
+	if err != nil {
+		return
+	}
+	var call struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(request, &call); err != nil || call.Method != "system_health" {
+		return
+	}
+	if s.hang {
+		io.Copy(io.Discard, rw)
+		return
+	}
+
+	// Server frames are sent unmasked
+	rw.Write([]byte{0x81, 126, byte(len(s.response) >> 8), byte(len(s.response))})
+	rw.WriteString(s.response)
+	rw.Flush()
+	readClientFrame(rw.Reader)
+}
+
+// readClientFrame reads a single masked client frame.
+func readClientFrame(r *bufio.Reader) ([]byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int(ext[0])<<8 | int(ext[1])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return payload, nil
+}
+
+func startHealthStub(t *testing.T, stub healthStub) string {
+	t.Helper()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestCheckEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		stub    healthStub
+		healthy bool
+		peers   int
+		err     string
+	}{
+		{
+			name:    "healthy",
+			stub:    healthStub{response: `{"jsonrpc":"2.0","id":1,"result":{"peers":12,"isSyncing":false,"shouldHavePeers":true}}`},
+			healthy: true,
+			peers:   12,
+		},
+		{
+			name:  "syncing",
+			stub:  healthStub{response: `{"jsonrpc":"2.0","id":1,"result":{"peers":8,"isSyncing":true,"shouldHavePeers":true}}`},
+			peers: 8,
+			err:   "node is syncing",
+		},
+		{
+			name: "no peers",
+			stub: healthStub{response: `{"jsonrpc":"2.0","id":1,"result":{"peers":0,"isSyncing":false,"shouldHavePeers":true}}`},
+			err:  "node has no peers",
+		},
+		{
+			name:    "no peers expected",
+			stub:    healthStub{response: `{"jsonrpc":"2.0","id":1,"result":{"peers":0,"isSyncing":false,"shouldHavePeers":false}}`},
+			healthy: true,
+		},
+		{
+			name: "rpc error",
+			stub: healthStub{response: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`},
+			err:  "system_health failed: Method not found",
+		},
+		{
+			name: "bad accept",
+			stub: healthStub{badAccept: true},
+			err:  "bad Sec-WebSocket-Accept",
+		},
+		{
+			name: "timeout",
+			stub: healthStub{hang: true},
+			err:  "i/o timeout",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint := startHealthStub(t, test.stub)
+			checker := NewHealthChecker(500 * time.Millisecond)
+
+			start := time.Now()
+			result := checker.CheckEndpoint(endpoint)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("check took %v", elapsed)
+			}
+
+			if result.Healthy != test.healthy {
+				t.Errorf("healthy = %v, want %v (error %q)", result.Healthy, test.healthy, result.Error)
+			}
+			if result.Peers != test.peers {
+				t.Errorf("peers = %d, want %d", result.Peers, test.peers)
+			}
+			if !strings.Contains(result.Error, test.err) || (test.err == "" && result.Error != "") {
+				t.Errorf("error = %q, want %q", result.Error, test.err)
+			}
+		})
+	}
+}
+
+func TestFilterHealthy(t *testing.T) {
+	healthy := startHealthStub(t, healthStub{response: `{"jsonrpc":"2.0","id":1,"result":{"peers":5,"isSyncing":false,"shouldHavePeers":true}}`})
+	syncing := startHealthStub(t, healthStub{response: `{"jsonrpc":"2.0","id":1,"result":{"peers":5,"isSyncing":true,"shouldHavePeers":true}}`})
+
+	members := []Member{
+		{ID: "alpha", Endpoints: map[string]string{"polkadot": healthy, "kusama": healthy}},
+		{ID: "beta", Endpoints: map[string]string{"polkadot": healthy, "kusama": syncing}},
+		{ID: "gamma"},
+		{ID: "delta", Endpoints: map[string]string{"westend": syncing}},
+	}
+
+	checker := NewHealthChecker(time.Second)
+	kept, results := checker.FilterHealthy(members, []string{"polkadot", "kusama"})
+
+	var ids []string
+	for _, member := range kept {
+		ids = append(ids, member.ID)
+	}
+	if got, want := strings.Join(ids, ","), "alpha,gamma,delta"; got != want {
+		t.Errorf("kept members %s, want %s", got, want)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for _, result := range results {
+		want := !(result.Member == "beta" && result.Chain == "kusama")
+		if result.Healthy != want {
+			t.Errorf("%s %s healthy = %v, want %v", result.Member, result.Chain, result.Healthy, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package geodns
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// kmPerMillisecond converts great-circle distance to an estimated round trip
+// time: light in fibre covers about 200 km per ms one way, and real routes
+// are roughly twice as long as the great circle.
+const kmPerMillisecond = 50
+
+// EstimateLatency returns the estimated round trip time in ms over a
+// great-circle distance in km.
+func EstimateLatency(distance float64) float64 {
+	return distance / kmPerMillisecond
+}
+
+// LatencyMatrix holds measured round trip times in ms, keyed by country name
+// or country code and then by member ID.
+type LatencyMatrix map[string]map[string]float64
+
+// Lookup returns the measured round trip time between a country and a member.
+func (l LatencyMatrix) Lookup(country Country, member Member) (float64, bool) {
+	for _, key := range []string{country.Name, country.CC} {
+		if rtt, ok := l[key][member.ID]; ok {
+			return rtt, true
+		}
+	}
+	return 0, false
+}
+
+type latencyFile struct {
+	Latencies LatencyMatrix `json:"latencies"`
+}
+
+// LoadLatencies reads a latency matrix produced by probes. JSON files map
+// countries to members to milliseconds under "latencies"; CSV files have a
+// country,member,rtt_ms row per measurement with an optional header.
+func LoadLatencies(filePath string) (LatencyMatrix, error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		return loadLatenciesCSV(filePath)
+	}
+
+	var file latencyFile
+	if err := loadJSON(filePath, &file); err != nil {
+		return nil, err
+	}
+	if file.Latencies == nil {
+		file.Latencies = make(LatencyMatrix)
+	}
+	for country, rtts := range file.Latencies {
+		for member, rtt := range rtts {
+			if rtt < 0 {
+				return nil, fmt.Errorf("%s: negative latency for %s to %s", filePath, country, member)
+			}
+		}
+	}
+	return file.Latencies, nil
+}
+
+func loadLatenciesCSV(filePath string) (LatencyMatrix, error) {
+	fileContents, err := ReadSource(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(fileContents))
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	latencies := make(LatencyMatrix)
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", filePath, err)
+		}
+
+		rtt, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			if line == 1 {
+				// Header
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid latency %q", filePath, line, row[2])
+		}
+		if rtt < 0 {
+			return nil, fmt.Errorf("%s:%d: negative latency", filePath, line)
+		}
+
+		if latencies[row[0]] == nil {
+			latencies[row[0]] = make(map[string]float64)
+		}
+		latencies[row[0]][row[1]] = rtt
+	}
+	return latencies, nil
+}
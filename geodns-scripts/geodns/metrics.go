@@ -0,0 +1,326 @@
+package geodns
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Labels are the label names and values of a metric series.
+type Labels map[string]string
+
+// labelValueEscaper escapes label values as the text exposition format
+// expects, leaving other characters as they are.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	var keys []string
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		value := labelValueEscaper.Replace(l[key])
+		pairs = append(pairs, key+`="`+value+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// matches reports whether the series labels include all of match.
+func (l Labels) matches(match Labels) bool {
+	for key, value := range match {
+		if l[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// durationBuckets are the histogram buckets, in seconds, of provider calls.
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type series struct {
+	labels Labels
+	value  float64
+
+	// Histograms only
+	buckets []uint64
+	count   uint64
+}
+
+type family struct {
+	kind   string
+	help   string
+	series map[string]*series
+}
+
+// Metrics is a minimal registry of gauges, counters and histograms served in
+// the Prometheus text format.
+type Metrics struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// DefaultMetrics is the registry used by the commands.
+var DefaultMetrics = NewMetrics()
+
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*family)}
+}
+
+func (m *Metrics) get(kind string, name string, help string, labels Labels) *series {
+	f, ok := m.families[name]
+	if !ok {
+		f = &family{kind: kind, help: help, series: make(map[string]*series)}
+		m.families[name] = f
+	}
+	key := labels.String()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		if kind == "histogram" {
+			s.buckets = make([]uint64, len(durationBuckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// SetGauge sets the value of a gauge.
+func (m *Metrics) SetGauge(name string, help string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get("gauge", name, help, labels).value = value
+}
+
+// ResetGauge removes the series of a gauge whose labels include match, so
+// that members or services that went away stop being reported.
+func (m *Metrics) ResetGauge(name string, match Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.families[name]
+	if !ok {
+		return
+	}
+	for key, s := range f.series {
+		if s.labels.matches(match) {
+			delete(f.series, key)
+		}
+	}
+}
+
+// AddCounter increases a counter.
+func (m *Metrics) AddCounter(name string, help string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get("counter", name, help, labels).value += value
+}
+
+// ObserveDuration adds a duration to a histogram.
+func (m *Metrics) ObserveDuration(name string, help string, labels Labels, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get("histogram", name, help, labels)
+	seconds := d.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.value += seconds
+	s.count++
+}
+
+// RecordHealth publishes health check results.
+func (m *Metrics) RecordHealth(results []HealthResult) {
+	for _, result := range results {
+		healthy := 0.0
+		if result.Healthy {
+			healthy = 1
+		}
+		m.SetGauge("geodns_member_endpoint_healthy", "Whether the last health check of a member endpoint passed.",
+			Labels{"member": result.Member, "chain": result.Chain}, healthy)
+	}
+}
+
+// RecordAssignments publishes the eligible members of a service and the
+// number of countries each of them serves, including members serving none,
+// and the number of countries served out of region.
+func (m *Metrics) RecordAssignments(service string, members []Member, assignments []Assignment) {
+	m.SetGauge("geodns_eligible_members", "Members eligible to serve a service.", Labels{"service": service}, float64(len(members)))
+
+	countries := make(map[string]int)
+	for _, member := range members {
+		countries[member.ID] = 0
+	}
+	outOfRegion := 0
+	for _, assignment := range assignments {
+		countries[assignment.Member.ID]++
+		if assignment.OutOfRegion() {
+			outOfRegion++
+		}
+	}
+	m.SetGauge("geodns_out_of_region_countries", "Countries served by a member outside their region.", Labels{"service": service}, float64(outOfRegion))
+
+	m.ResetGauge("geodns_countries_per_member", Labels{"service": service})
+	for member, count := range countries {
+		m.SetGauge("geodns_countries_per_member", "Countries assigned to a member as primary answer.",
+			Labels{"service": service, "member": member}, float64(count))
+	}
+}
+
+// ServeHTTP writes every metric in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	var names []string
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := m.families[name]
+		fmt.Fprintf(w, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, f.kind)
+
+		var keys []string
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != "histogram" {
+				fmt.Fprintf(w, "%s%s %v\n", name, key, s.value)
+				continue
+			}
+
+			for i, bound := range durationBuckets {
+				fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", fmt.Sprint(bound)), s.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_sum%s %v\n", name, key, s.value)
+			fmt.Fprintf(w, "%s_count%s %d\n", name, key, s.count)
+		}
+	}
+}
+
+func withLabel(labels Labels, key string, value string) Labels {
+	copied := Labels{key: value}
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// instrumentedProvider times every call of a provider and counts the record
+// writes by outcome. Writes to providers that batch them are counted when
+// the batch is committed.
+type instrumentedProvider struct {
+	Provider
+	metrics *Metrics
+
+	mu      sync.Mutex
+	pending map[string]map[string]float64
+}
+
+// InstrumentProvider wraps a provider so that its API calls are reported in
+// the metrics.
+func InstrumentProvider(p Provider, metrics *Metrics) Provider {
+	return &instrumentedProvider{Provider: p, metrics: metrics}
+}
+
+func (i *instrumentedProvider) observe(operation string, start time.Time) {
+	labels := Labels{"provider": i.Name(), "operation": operation}
+	i.metrics.ObserveDuration("geodns_provider_request_duration_seconds", "Duration of provider API calls.", labels, time.Since(start))
+}
+
+func (i *instrumentedProvider) countWrites(operation string, err error, count float64) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	i.metrics.AddCounter("geodns_records_total", "Record writes sent to the provider by operation and result.",
+		Labels{"provider": i.Name(), "operation": operation, "result": result}, count)
+}
+
+// write times a record write and counts it, or for providers that batch
+// writes keeps it until the batch is committed.
+func (i *instrumentedProvider) write(domain string, operation string, start time.Time, err error) {
+	i.observe(operation, start)
+	if _, ok := i.Provider.(BatchProvider); !ok || err != nil {
+		i.countWrites(operation, err, 1)
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.pending == nil {
+		i.pending = make(map[string]map[string]float64)
+	}
+	if i.pending[domain] == nil {
+		i.pending[domain] = make(map[string]float64)
+	}
+	i.pending[domain][operation]++
+}
+
+func (i *instrumentedProvider) ListRecords(domain string) ([]Record, error) {
+	start := time.Now()
+	records, err := i.Provider.ListRecords(domain)
+	i.observe("list", start)
+	return records, err
+}
+
+func (i *instrumentedProvider) CreateRecord(domain string, record Record) error {
+	start := time.Now()
+	err := i.Provider.CreateRecord(domain, record)
+	i.write(domain, ActionCreate, start, err)
+	return err
+}
+
+func (i *instrumentedProvider) UpdateRecord(domain string, record Record) error {
+	start := time.Now()
+	err := i.Provider.UpdateRecord(domain, record)
+	i.write(domain, ActionUpdate, start, err)
+	return err
+}
+
+func (i *instrumentedProvider) DeleteRecord(domain string, record Record) error {
+	start := time.Now()
+	err := i.Provider.DeleteRecord(domain, record)
+	i.write(domain, ActionDelete, start, err)
+	return err
+}
+
+// Commit sends the queued changes of providers that batch them.
+func (i *instrumentedProvider) Commit(domain string) error {
+	batch, ok := i.Provider.(BatchProvider)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := batch.Commit(domain)
+	i.observe("commit", start)
+
+	i.mu.Lock()
+	pending := i.pending[domain]
+	delete(i.pending, domain)
+	i.mu.Unlock()
+	for operation, count := range pending {
+		i.countWrites(operation, err, count)
+	}
+	return err
+}
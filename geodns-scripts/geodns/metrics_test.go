@@ -0,0 +1,111 @@
+package geodns
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLabelsString(t *testing.T) {
+	labels := Labels{"service": "rpc.ibp.network", "member": "Zürich \"dc\"\n\\1"}
+	want := `{member="Zürich \"dc\"\n\\1",service="rpc.ibp.network"}`
+	if got := labels.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// fakeProvider stores records in memory.
+type fakeProvider struct {
+	records []Record
+}
+
+func (f *fakeProvider) Name() string                   { return "fake" }
+func (f *fakeProvider) LocationID(country Country) int { return 0 }
+func (f *fakeProvider) ListRecords(domain string) ([]Record, error) {
+	return f.records, nil
+}
+
+func (f *fakeProvider) CreateRecord(domain string, record Record) error {
+	f.records = append(f.records, record)
+	return nil
+}
+
+func (f *fakeProvider) UpdateRecord(domain string, record Record) error {
+	return errors.New("update failed")
+}
+
+func (f *fakeProvider) DeleteRecord(domain string, record Record) error {
+	return nil
+}
+
+// fakeBatchProvider queues writes until Commit, which fails when commitErr
+// is set.
+type fakeBatchProvider struct {
+	fakeProvider
+	commitErr error
+}
+
+func (f *fakeBatchProvider) Commit(domain string) error {
+	return f.commitErr
+}
+
+func scrape(metrics *Metrics) string {
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestInstrumentProviderCountsWrites(t *testing.T) {
+	metrics := NewMetrics()
+	provider := InstrumentProvider(&fakeProvider{}, metrics)
+	provider.CreateRecord("example.com", Record{Host: "rpc"})
+	provider.UpdateRecord("example.com", Record{Host: "rpc"})
+	provider.ListRecords("example.com")
+	provider.(BatchProvider).Commit("example.com")
+
+	output := scrape(metrics)
+	for _, line := range []string{
+		`geodns_records_total{operation="create",provider="fake",result="success"} 1`,
+		`geodns_records_total{operation="update",provider="fake",result="failure"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, `geodns_records_total{operation="commit"`) || strings.Contains(output, `geodns_records_total{operation="list"`) {
+		t.Errorf("commit or list counted as record writes:\n%s", output)
+	}
+}
+
+func TestInstrumentBatchProviderCountsOnCommit(t *testing.T) {
+	metrics := NewMetrics()
+	batch := &fakeBatchProvider{}
+	provider := InstrumentProvider(batch, metrics).(BatchProvider)
+
+	provider.CreateRecord("example.com", Record{Host: "rpc"})
+	provider.CreateRecord("example.com", Record{Host: "rpc"})
+	provider.DeleteRecord("example.com", Record{Host: "rpc"})
+	if output := scrape(metrics); strings.Contains(output, "geodns_records_total") {
+		t.Fatalf("queued writes counted before the commit:\n%s", output)
+	}
+
+	if err := provider.Commit("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	batch.commitErr = errors.New("throttled")
+	provider.DeleteRecord("example.com", Record{Host: "rpc"})
+	provider.Commit("example.com")
+
+	output := scrape(metrics)
+	for _, line := range []string{
+		`geodns_records_total{operation="create",provider="fake",result="success"} 2`,
+		`geodns_records_total{operation="delete",provider="fake",result="success"} 1`,
+		`geodns_records_total{operation="delete",provider="fake",result="failure"} 1`,
+		`geodns_provider_request_duration_seconds_count{operation="commit",provider="fake"} 2`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, output)
+		}
+	}
+}
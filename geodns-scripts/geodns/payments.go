@@ -0,0 +1,110 @@
+package geodns
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PaymentNetworks maps the networks of member payments to the SS58 prefix
+// of their addresses.
+var PaymentNetworks = map[string]uint16{
+	"kusama":   2,
+	"polkadot": 0,
+}
+
+// VerifyPayment checks that the signature of a payment was made with the
+// key of the validator address over the payment address. Signatures may be
+// sr25519 or ed25519, over the address itself or wrapped in <Bytes> tags as
+// signed by browser wallets.
+func VerifyPayment(network string, payment Payment) error {
+	prefix, ok := PaymentNetworks[network]
+	if !ok {
+		return fmt.Errorf("unknown network %q", network)
+	}
+	if payment.Signature == "" {
+		return fmt.Errorf("missing signature")
+	}
+	if payment.ValidatorAddress == "" {
+		return fmt.Errorf("missing validator address")
+	}
+	if payment.PaymentAddress == "" {
+		return fmt.Errorf("missing payment address")
+	}
+
+	validatorPrefix, publicKey, err := DecodeSS58(payment.ValidatorAddress)
+	if err != nil {
+		return fmt.Errorf("invalid validator address: %v", err)
+	}
+	if validatorPrefix != prefix {
+		return fmt.Errorf("validator address has network prefix %d, %s uses %d", validatorPrefix, network, prefix)
+	}
+	paymentPrefix, _, err := DecodeSS58(payment.PaymentAddress)
+	if err != nil {
+		return fmt.Errorf("invalid payment address: %v", err)
+	}
+	if paymentPrefix != prefix {
+		return fmt.Errorf("payment address has network prefix %d, %s uses %d", paymentPrefix, network, prefix)
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(payment.Signature, "0x"))
+	if err != nil || len(signature) != 64 {
+		return fmt.Errorf("signature is not 64 hex encoded bytes")
+	}
+
+	address := []byte(payment.PaymentAddress)
+	wrapped := bytes.Join([][]byte{[]byte("<Bytes>"), address, []byte("</Bytes>")}, nil)
+	for _, msg := range [][]byte{address, wrapped} {
+		if VerifySr25519(publicKey, msg, signature) || ed25519.Verify(publicKey, msg, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature of the payment address does not match the validator key")
+}
+
+// ValidatePayments checks the payment signatures of every member of
+// members.json for every network in PaymentNetworks.
+func ValidatePayments(filePath string) []Problem {
+	v := &validator{file: filePath}
+
+	var members Members
+	if !v.decode(&members) {
+		return v.problems
+	}
+
+	var networks []string
+	for network := range PaymentNetworks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	for _, id := range sortedMemberIDs(members) {
+		member := members.Members[id]
+		key := "members." + id + ".payments"
+
+		for _, network := range networks {
+			payment, ok := member.Payments[network]
+			if !ok {
+				v.add(key, "missing %s payment", network)
+				continue
+			}
+			if err := VerifyPayment(network, payment); err != nil {
+				v.add(key+"."+network, "%v", err)
+			}
+		}
+		var unknown []string
+		for network := range member.Payments {
+			if _, ok := PaymentNetworks[network]; !ok {
+				unknown = append(unknown, network)
+			}
+		}
+		sort.Strings(unknown)
+		for _, network := range unknown {
+			v.add(key+"."+network, "unknown network, use one of %s", strings.Join(networks, ", "))
+		}
+	}
+	return v.problems
+}
@@ -0,0 +1,74 @@
+package geodns
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// flipSignatureBit returns a hex signature with one bit inverted.
+func flipSignatureBit(t *testing.T, signature string, bit int) string {
+	t.Helper()
+	return "0x" + hex.EncodeToString(flipBit(mustHex(t, strings.TrimPrefix(signature, "0x")), bit))
+}
+
+func TestVerifyPayment(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		payment Payment
+	}{
+		{
+			name:    "sr25519 kusama",
+			network: "kusama",
+			payment: Payment{
+				ValidatorAddress: "F8FswEGD1quEyNciuR9KwsragUmqnk7mFLqmAdRdnY9Sdwx",
+				PaymentAddress:   "HERTWZK4ZpmuXsEgW3UfL5x27XCdT5fE9x4QRbyNWifafdU",
+				Signature:        "0x52df0775a054197b56d1a6bdf1fdf2365b09f1b6dc784ab5118e36c2bfa7a23f02c782d3c24b3056b82e74cf5550db22d0d457d4dc7f249d5aac2526d7f76681",
+			},
+		},
+		{
+			name:    "sr25519 polkadot",
+			network: "polkadot",
+			payment: Payment{
+				ValidatorAddress: "152QidDC4QrtMCyRGiQmvrNyjntvMg2XouCSdoPSeqUNTvsq",
+				PaymentAddress:   "1EHRUR7YL8aeqeCT4LGFq9qrTpEiH1dCikh3n2j1CSbL2c8",
+				Signature:        "0x6432c58975e69d5956e7baf0d3b0b5f3c21792138d60adc2b4e48e9420092d6b74743520ba93ca7b956e9446e8c68f7b9390b4ce6545a5790bed874e48e8f688",
+			},
+		},
+		{
+			// The key of RFC 8032 test 1 as a kusama address
+			name:    "ed25519 kusama",
+			network: "kusama",
+			payment: Payment{
+				ValidatorAddress: "HSgj13mnepYxuysui2XroHKigftFpQsg1dcSfA9PckdZJW4",
+				PaymentAddress:   "HERTWZK4ZpmuXsEgW3UfL5x27XCdT5fE9x4QRbyNWifafdU",
+				Signature:        "0x8e01ad5cc15ea82fbd2dc5b98329154def3c069cc411647ee83c630a6ddefd7cd1cc25f02165f5080290d9e619ae7fb2d72d370399b2bdb8780b60a27885b505",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := VerifyPayment(test.network, test.payment); err != nil {
+				t.Fatalf("valid payment rejected: %v", err)
+			}
+
+			for _, bit := range []int{3, 300} {
+				flipped := test.payment
+				flipped.Signature = flipSignatureBit(t, test.payment.Signature, bit)
+				if err := VerifyPayment(test.network, flipped); err == nil {
+					t.Errorf("signature with bit %d flipped accepted", bit)
+				}
+			}
+
+			other := "kusama"
+			if test.network == "kusama" {
+				other = "polkadot"
+			}
+			if err := VerifyPayment(other, test.payment); err == nil || !strings.Contains(err.Error(), "network prefix") {
+				t.Errorf("payment accepted on %s: %v", other, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package geodns
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change is a single record write needed to reach the assignment.
+type Change struct {
+	Action   string  `json:"action"`
+	Country  string  `json:"country,omitempty"`
+	Location int     `json:"location"`
+	OldIP    string  `json:"old_ip,omitempty"`
+	NewIP    string  `json:"new_ip,omitempty"`
+	Member   string  `json:"member,omitempty"`
+	Distance float64 `json:"distance"`
+	Latency  float64 `json:"latency_ms"`
+	Measured bool    `json:"latency_measured"`
+
+	// Record is what gets sent to the provider.
+	Record Record `json:"-"`
+}
+
+// Plan holds the changes for one host of a domain on a provider.
+type Plan struct {
+	Provider string   `json:"provider"`
+	Domain   string   `json:"domain"`
+	Host     string   `json:"host"`
+	Type     string   `json:"type"`
+	Changes  []Change `json:"changes"`
+}
+
+// PlanOptions controls how the records of a host are reconciled.
+type PlanOptions struct {
+	TTL int
+
+	// Type is the record type to reconcile, A when empty. Answers are
+	// published with the member address of that type.
+	Type string
+
+	// Prune deletes geo records of the host that are not needed for any
+	// assignment, such as records of removed countries, duplicates and
+	// surplus answers after the number of answers was lowered.
+	Prune bool
+}
+
+// NewPlan reads the current records from the provider and computes the
+// changes needed to publish the assignments.
+func NewPlan(p Provider, domain string, host string, assignments []Assignment, opts PlanOptions) (Plan, error) {
+	records, err := p.ListRecords(domain)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	// Sort by ID so that the same records are reused on every run
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+
+	recordType := opts.Type
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	plan := Plan{Provider: p.Name(), Domain: domain, Host: host, Type: recordType, Changes: []Change{}}
+	kept := make(map[string]bool)
+	names := make(map[int]string)
+	for _, assignment := range assignments {
+		location := p.LocationID(assignment.Country)
+		names[location] = assignment.Country.Name
+
+		var existing []*Record
+		for i := range records {
+			if records[i].Host == host && records[i].Type == recordType && records[i].Location == location {
+				existing = append(existing, &records[i])
+			}
+		}
+
+		// Keep the records that already hold one of the answers so that
+		// duplicates are the ones pruned.
+		var missing []Candidate
+		for _, answer := range assignment.Answers() {
+			found := false
+			for _, record := range existing {
+				if !kept[record.ID] && record.Value == answer.Member.Address(recordType) {
+					kept[record.ID] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, answer)
+			}
+		}
+
+		for _, answer := range missing {
+			change := Change{
+				Country:  assignment.Country.Name,
+				Location: location,
+				NewIP:    answer.Member.Address(recordType),
+				Member:   answer.Member.ID,
+				Distance: answer.Distance,
+				Latency:  answer.Latency,
+				Measured: answer.Measured,
+				Record: Record{
+					Host:     host,
+					Type:     recordType,
+					TTL:      opts.TTL,
+					Value:    answer.Member.Address(recordType),
+					Location: location,
+				},
+			}
+
+			var reuse *Record
+			for _, record := range existing {
+				if !kept[record.ID] {
+					reuse = record
+					break
+				}
+			}
+
+			if reuse == nil {
+				// No Record, Create new one
+				change.Action = ActionCreate
+			} else {
+				// Record found, update
+				kept[reuse.ID] = true
+				change.Action = ActionUpdate
+				change.OldIP = reuse.Value
+				change.Record.ID = reuse.ID
+			}
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	if opts.Prune {
+		for _, record := range records {
+			// Records without a geo location are the zone default and are
+			// never pruned.
+			if record.Host != host || record.Type != recordType || record.Location == 0 || kept[record.ID] {
+				continue
+			}
+			plan.Changes = append(plan.Changes, Change{
+				Action:   ActionDelete,
+				Country:  names[record.Location],
+				Location: record.Location,
+				OldIP:    record.Value,
+				Record:   record,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// CurrentMembers reads the records of a host and returns the IDs of the
+// members they point to by country name, for AssignOptions.Current.
+// Records of addresses no member has are ignored.
+func CurrentMembers(p Provider, domain string, host string, recordType string, countries []Country, members []Member) (map[string][]string, error) {
+	records, err := p.ListRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	byAddress := make(map[string]string)
+	for _, member := range members {
+		if address := member.Address(recordType); address != "" {
+			byAddress[address] = member.ID
+		}
+	}
+
+	byLocation := make(map[int][]string)
+	for _, record := range records {
+		id, ok := byAddress[record.Value]
+		if record.Host == host && record.Type == recordType && ok {
+			byLocation[record.Location] = append(byLocation[record.Location], id)
+		}
+	}
+
+	current := make(map[string][]string)
+	for _, country := range countries {
+		if ids, ok := byLocation[p.LocationID(country)]; ok {
+			current[country.Name] = ids
+		}
+	}
+	return current, nil
+}
+
+// Count returns the number of changes with the given action.
+func (plan Plan) Count(action string) int {
+	count := 0
+	for _, change := range plan.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Print writes a human readable summary of the plan.
+func (plan Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "Plan for %s.%s %s on %s: %d to create, %d to update, %d to delete\n",
+		plan.Host, plan.Domain, plan.Type, plan.Provider,
+		plan.Count(ActionCreate), plan.Count(ActionUpdate), plan.Count(ActionDelete))
+
+	for _, change := range plan.Changes {
+		symbol := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}[change.Action]
+		country := change.Country
+		if country == "" {
+			country = "unassigned"
+		}
+		estimated := "~"
+		if change.Measured {
+			estimated = " "
+		}
+		fmt.Fprintf(w, "  %s %-40s %-15s -> %-15s %-12s %8.0f km %s%5.0f ms\n",
+			symbol, fmt.Sprintf("%s (%d)", country, change.Location),
+			change.OldIP, change.NewIP, change.Member, change.Distance, estimated, change.Latency)
+	}
+}
+
+// Apply sends the changes of the plan to the provider and adds the outcome
+// of every change to the report, which may be nil. Failed changes are
+// reported and skipped. Changes queued by a batch provider are reported as
+// failed when committing them fails.
+func Apply(p Provider, plan Plan, report *Report) error {
+	results := make([]Result, len(plan.Changes))
+	errs := make([]error, len(plan.Changes))
+	for i, change := range plan.Changes {
+		var err error
+		switch change.Action {
+		case ActionCreate:
+			fmt.Printf("Creating record for %s\n", change.Country)
+			err = p.CreateRecord(plan.Domain, change.Record)
+		case ActionUpdate:
+			fmt.Printf("Updating record %s\n", change.Record.ID)
+			err = p.UpdateRecord(plan.Domain, change.Record)
+		case ActionDelete:
+			fmt.Printf("Deleting record %s\n", change.Record.ID)
+			err = p.DeleteRecord(plan.Domain, change.Record)
+		}
+
+		results[i] = Result{Provider: p.Name(), Domain: plan.Domain, Host: plan.Host, Action: change.Action, Country: change.Country}
+		errs[i] = err
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
+
+	if batch, ok := p.(BatchProvider); ok && len(plan.Changes) > 0 {
+		if err := batch.Commit(plan.Domain); err != nil {
+			fmt.Printf("%v\n", err)
+			for i := range errs {
+				if errs[i] == nil {
+					errs[i] = err
+				}
+			}
+		}
+	}
+
+	failed := 0
+	for i := range results {
+		report.Add(results[i], errs[i])
+		if errs[i] != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records failed to reconcile on %s", failed, len(plan.Changes), p.Name())
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package geodns
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordProvider lists a fixed set of records and locates countries by
+// their code.
+type recordProvider struct {
+	records   []Record
+	locations map[string]int
+}
+
+func (r *recordProvider) Name() string { return "records" }
+func (r *recordProvider) LocationID(country Country) int {
+	return r.locations[country.CC]
+}
+
+func (r *recordProvider) ListRecords(domain string) ([]Record, error) {
+	return append([]Record{}, r.records...), nil
+}
+
+func (r *recordProvider) CreateRecord(domain string, record Record) error { return nil }
+func (r *recordProvider) UpdateRecord(domain string, record Record) error { return nil }
+func (r *recordProvider) DeleteRecord(domain string, record Record) error { return nil }
+
+var planLocations = map[string]int{"DE": 1, "FR": 2, "GB": 3}
+
+func assignTo(cc string, members ...Member) Assignment {
+	assignment := Assignment{Country: Country{Name: cc, CC: cc}, Candidate: Candidate{Member: members[0]}}
+	for _, member := range members[1:] {
+		assignment.Backups = append(assignment.Backups, Candidate{Member: member})
+	}
+	return assignment
+}
+
+func geoRecord(id string, location int, value string) Record {
+	return Record{ID: id, Host: "rpc", Type: "A", TTL: 60, Value: value, Location: location}
+}
+
+// planChanges formats the changes of a plan as "action id location old>new".
+func planChanges(plan Plan) []string {
+	changes := []string{}
+	for _, change := range plan.Changes {
+		changes = append(changes, fmt.Sprintf("%s %s %d %s>%s", change.Action, change.Record.ID, change.Location, change.OldIP, change.NewIP))
+	}
+	return changes
+}
+
+func TestNewPlanPrune(t *testing.T) {
+	alpha := Member{ID: "alpha", ServicesAddress: "192.0.2.1"}
+	beta := Member{ID: "beta", ServicesAddress: "192.0.2.2"}
+
+	tests := []struct {
+		name        string
+		records     []Record
+		assignments []Assignment
+		prune       bool
+		want        []string
+	}{
+		{
+			name: "duplicates of a location",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.1"),
+				geoRecord("2", 1, "192.0.2.1"),
+				geoRecord("3", 1, "192.0.2.9"),
+			},
+			assignments: []Assignment{assignTo("DE", alpha)},
+			prune:       true,
+			want:        []string{"delete 2 1 192.0.2.1>", "delete 3 1 192.0.2.9>"},
+		},
+		{
+			name: "the record holding the answer is kept",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.9"),
+				geoRecord("2", 1, "192.0.2.2"),
+			},
+			assignments: []Assignment{assignTo("DE", beta)},
+			prune:       true,
+			want:        []string{"delete 1 1 192.0.2.9>"},
+		},
+		{
+			name: "orphans are deleted, the default and other hosts and types are kept",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.1"),
+				geoRecord("2", 7, "192.0.2.2"),
+				geoRecord("3", 0, "192.0.2.2"),
+				geoRecord("4", 0, "192.0.2.1"),
+				{ID: "5", Host: "sys", Type: "A", Value: "192.0.2.2", Location: 7},
+				{ID: "6", Host: "rpc", Type: "AAAA", Value: "2001:db8::2", Location: 7},
+			},
+			assignments: []Assignment{assignTo("DE", alpha)},
+			prune:       true,
+			want:        []string{"delete 2 7 192.0.2.2>"},
+		},
+		{
+			name: "new locations are created next to pruned duplicates",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.1"),
+				geoRecord("2", 1, "192.0.2.1"),
+			},
+			assignments: []Assignment{assignTo("DE", alpha), assignTo("FR", beta)},
+			prune:       true,
+			want:        []string{"create  2 >192.0.2.2", "delete 2 1 192.0.2.1>"},
+		},
+		{
+			name: "nothing is deleted without prune",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.1"),
+				geoRecord("2", 1, "192.0.2.1"),
+				geoRecord("3", 7, "192.0.2.2"),
+			},
+			assignments: []Assignment{assignTo("DE", alpha)},
+			want:        []string{},
+		},
+		{
+			name: "a host without assignments loses everything but the default",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.1"),
+				geoRecord("2", 2, "192.0.2.2"),
+				geoRecord("3", 0, "192.0.2.1"),
+			},
+			prune: true,
+			want:  []string{"delete 1 1 192.0.2.1>", "delete 2 2 192.0.2.2>"},
+		},
+	}
+
+	for _, test := range tests {
+		provider := &recordProvider{records: test.records, locations: planLocations}
+		plan, err := NewPlan(provider, "ibp.network", "rpc", test.assignments, PlanOptions{TTL: 60, Prune: test.prune})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := planChanges(plan); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewPlanAnswers(t *testing.T) {
+	alpha := Member{ID: "alpha", ServicesAddress: "192.0.2.1", ServicesAddressV6: "2001:db8::1"}
+	beta := Member{ID: "beta", ServicesAddress: "192.0.2.2", ServicesAddressV6: "2001:db8::2"}
+	gamma := Member{ID: "gamma", ServicesAddress: "192.0.2.3"}
+
+	tests := []struct {
+		name        string
+		records     []Record
+		assignments []Assignment
+		opts        PlanOptions
+		want        []string
+	}{
+		{
+			name:        "every answer of a new location is created",
+			assignments: []Assignment{assignTo("DE", alpha, beta)},
+			want:        []string{"create  1 >192.0.2.1", "create  1 >192.0.2.2"},
+		},
+		{
+			name:        "a stale answer is updated to the missing one",
+			records:     []Record{geoRecord("1", 1, "192.0.2.1"), geoRecord("2", 1, "192.0.2.9")},
+			assignments: []Assignment{assignTo("DE", alpha, beta)},
+			want:        []string{"update 2 1 192.0.2.9>192.0.2.2"},
+		},
+		{
+			name:        "raising the answers creates the new ones",
+			records:     []Record{geoRecord("1", 1, "192.0.2.1")},
+			assignments: []Assignment{assignTo("DE", alpha, beta, gamma)},
+			want:        []string{"create  1 >192.0.2.2", "create  1 >192.0.2.3"},
+		},
+		{
+			name:        "lowering the answers deletes the surplus with prune",
+			records:     []Record{geoRecord("1", 1, "192.0.2.1"), geoRecord("2", 1, "192.0.2.2")},
+			assignments: []Assignment{assignTo("DE", alpha)},
+			opts:        PlanOptions{Prune: true},
+			want:        []string{"delete 2 1 192.0.2.2>"},
+		},
+		{
+			name:        "reordered answers are left alone",
+			records:     []Record{geoRecord("1", 1, "192.0.2.1"), geoRecord("2", 1, "192.0.2.2")},
+			assignments: []Assignment{assignTo("DE", beta, alpha)},
+			want:        []string{},
+		},
+		{
+			name:        "a changed answer of one location among several",
+			records:     []Record{geoRecord("1", 1, "192.0.2.1"), geoRecord("2", 1, "192.0.2.2"), geoRecord("3", 2, "192.0.2.2"), geoRecord("4", 2, "192.0.2.1")},
+			assignments: []Assignment{assignTo("DE", alpha, beta), assignTo("FR", beta, gamma)},
+			opts:        PlanOptions{Prune: true},
+			want:        []string{"update 4 2 192.0.2.1>192.0.2.3"},
+		},
+		{
+			name: "AAAA answers use the IPv6 addresses",
+			records: []Record{
+				geoRecord("1", 1, "192.0.2.1"),
+				{ID: "2", Host: "rpc", Type: "AAAA", Value: "2001:db8::9", Location: 1},
+			},
+			assignments: []Assignment{assignTo("DE", alpha, beta)},
+			opts:        PlanOptions{Type: "AAAA"},
+			want:        []string{"update 2 1 2001:db8::9>2001:db8::1", "create  1 >2001:db8::2"},
+		},
+	}
+
+	for _, test := range tests {
+		provider := &recordProvider{records: test.records, locations: planLocations}
+		test.opts.TTL = 60
+		plan, err := NewPlan(provider, "ibp.network", "rpc", test.assignments, test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := planChanges(plan); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		for _, change := range plan.Changes {
+			if change.Action != ActionDelete && (change.Record.Type != plan.Type || change.Record.TTL != 60 || change.Member == "") {
+				t.Errorf("%s: unexpected record %+v of %s", test.name, change.Record, change.Member)
+			}
+		}
+	}
+}
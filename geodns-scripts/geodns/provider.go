@@ -0,0 +1,36 @@
+package geodns
+
+// Record is a geo located DNS record as seen by a provider.
+type Record struct {
+	ID       string
+	Host     string
+	Type     string
+	TTL      int
+	Value    string
+	Location int
+}
+
+// Provider is a GeoDNS service holding the records of one or more domains.
+type Provider interface {
+	// Name identifies the provider in log output.
+	Name() string
+
+	// LocationID returns the provider specific geo location of a country.
+	LocationID(country Country) int
+
+	// ListRecords returns every geo located record of the domain.
+	ListRecords(domain string) ([]Record, error)
+
+	CreateRecord(domain string, record Record) error
+	UpdateRecord(domain string, record Record) error
+	DeleteRecord(domain string, record Record) error
+}
+
+// BatchProvider is a provider that queues record changes and sends them with
+// Commit, such as Route53 with its change batches.
+type BatchProvider interface {
+	Provider
+
+	// Commit sends the queued changes of the domain.
+	Commit(domain string) error
+}
@@ -0,0 +1,55 @@
+package geodns
+
+import (
+	"fmt"
+	"io"
+)
+
+// Regions are the member regions of members.json, which countries files map
+// their entries to.
+var Regions = []string{"africa", "asia", "central_america", "europe", "middle_east", "north_america", "oceania", "south_america"}
+
+// ValidRegion reports whether region is one of Regions.
+func ValidRegion(region string) bool {
+	for _, known := range Regions {
+		if region == known {
+			return true
+		}
+	}
+	return false
+}
+
+// InRegion reports whether a member is in the region of a country.
+// Countries without a region are in every region.
+func InRegion(country Country, member Member) bool {
+	return country.Region == "" || country.Region == member.Region
+}
+
+// OutOfRegion reports whether the country is served by a member outside
+// its region.
+func (a Assignment) OutOfRegion() bool {
+	return !InRegion(a.Country, a.Member)
+}
+
+// PrintOutOfRegion writes the countries of a service that are served by a
+// member outside their region and returns how many there are.
+func PrintOutOfRegion(w io.Writer, service string, assignments []Assignment) int {
+	count := 0
+	for _, assignment := range assignments {
+		if assignment.OutOfRegion() {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	fmt.Fprintf(w, "Service %s: %d countries served out of region\n", service, count)
+	for _, assignment := range assignments {
+		if assignment.OutOfRegion() {
+			fmt.Fprintf(w, "  %-40s %-15s -> %s (%s)\n", assignment.Country.Name, assignment.Country.Region,
+				assignment.Member.ID, assignment.Member.Region)
+		}
+	}
+	return count
+}
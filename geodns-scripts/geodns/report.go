@@ -0,0 +1,146 @@
+package geodns
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Result is the outcome of a record change, or of a step that failed before
+// any change could be made, such as listing the records of a domain.
+type Result struct {
+	Provider string `json:"provider"`
+	Domain   string `json:"domain"`
+	Host     string `json:"host"`
+	Action   string `json:"action"`
+	Country  string `json:"country,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	// Kind classifies failed provider calls, see ErrorKind.
+	Kind string `json:"kind,omitempty"`
+
+	// Attempts is the number of tries of a failed provider call.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Report collects the results of a run across services and providers.
+type Report struct {
+	mu      sync.Mutex
+	Results []Result `json:"results"`
+}
+
+// ErrorKind names the kind of a provider error for reporting.
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrAuth):
+		return "auth"
+	case errors.Is(err, ErrRateLimit):
+		return "rate_limit"
+	case errors.Is(err, ErrValidation):
+		return "validation"
+	case errors.Is(err, ErrNetwork):
+		return "network"
+	case errors.Is(err, ErrProvider):
+		return "provider"
+	default:
+		return "other"
+	}
+}
+
+// Add records a result. A nil report discards it.
+func (r *Report) Add(result Result, err error) {
+	if r == nil {
+		return
+	}
+	if err != nil {
+		result.Error = Redact(err.Error())
+		result.Kind = ErrorKind(err)
+		var perr *ProviderError
+		if errors.As(err, &perr) {
+			result.Attempts = perr.Attempts
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Results = append(r.Results, result)
+}
+
+// Failed returns the number of failed results.
+func (r *Report) Failed() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := 0
+	for _, result := range r.Results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Print writes a summary per provider and action, followed by every
+// failure and the number of them that failed even after retrying.
+func (r *Report) Print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type key struct {
+		provider string
+		action   string
+	}
+	succeeded := make(map[key]int)
+	failed := make(map[key]int)
+	var keys []key
+	for _, result := range r.Results {
+		k := key{result.Provider, result.Action}
+		if succeeded[k] == 0 && failed[k] == 0 {
+			keys = append(keys, k)
+		}
+		if result.Error != "" {
+			failed[k]++
+		} else {
+			succeeded[k]++
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		return keys[i].action < keys[j].action
+	})
+
+	fmt.Fprintf(w, "Run report:\n")
+	if len(keys) == 0 {
+		fmt.Fprintf(w, "  no changes\n")
+	}
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-10s %-8s %d succeeded, %d failed\n", k.provider, k.action, succeeded[k], failed[k])
+	}
+
+	retried := 0
+	for _, result := range r.Results {
+		if result.Error == "" {
+			continue
+		}
+		if result.Attempts > 1 {
+			retried++
+		}
+		target := result.Host + "." + result.Domain
+		if result.Country != "" {
+			target += " " + result.Country
+		}
+		fmt.Fprintf(w, "  FAILED %s %s [%s]: %s\n", result.Action, target, result.Kind, result.Error)
+	}
+	if retried > 0 {
+		fmt.Fprintf(w, "  %d calls failed after retrying\n", retried)
+	}
+}
@@ -0,0 +1,610 @@
+package geodns
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	route53URL    = "https://route53.amazonaws.com"
+	route53Region = "us-east-1"
+
+	// route53BatchSize keeps change batches well below the limit of 1000
+	// resource records per ChangeResourceRecordSets call.
+	route53BatchSize = 100
+)
+
+// Route53RateLimit is the default number of Route53 requests per second,
+// which AWS enforces per account.
+const Route53RateLimit = 5
+
+// GeoLocation is a Route53 geolocation: a continent, a country or a
+// subdivision of a country. CountryCode "*" is the default location.
+type GeoLocation struct {
+	ContinentCode   string `json:"continent_code,omitempty" xml:"ContinentCode,omitempty"`
+	CountryCode     string `json:"country_code,omitempty" xml:"CountryCode,omitempty"`
+	SubdivisionCode string `json:"subdivision_code,omitempty" xml:"SubdivisionCode,omitempty"`
+}
+
+// key identifies the geolocation, such as DE, US-CA or continent-EU.
+func (g GeoLocation) key() string {
+	switch {
+	case g.ContinentCode != "":
+		return "continent-" + g.ContinentCode
+	case g.CountryCode == "*":
+		return "default"
+	case g.SubdivisionCode != "":
+		return g.CountryCode + "-" + g.SubdivisionCode
+	default:
+		return g.CountryCode
+	}
+}
+
+// Route53 talks to the AWS Route53 API. A geo location maps to one record
+// set per host and type, holding every answer for it. Record changes are
+// queued and sent in ChangeResourceRecordSets batches by Commit.
+type Route53 struct {
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// ZoneIDs maps domains to hosted zone IDs. Missing zones are looked up
+	// by name.
+	ZoneIDs map[string]string
+
+	// BaseURL defaults to the public Route53 API.
+	BaseURL   string
+	Transport *Transport
+
+	mu        sync.Mutex
+	locations map[string]int
+	zones     map[string]*route53Zone
+}
+
+// route53Zone holds the record sets of a hosted zone as listed and as they
+// should be after the queued changes.
+type route53Zone struct {
+	id      string
+	current map[string]route53RecordSet
+	desired map[string]route53RecordSet
+}
+
+type route53ResourceRecord struct {
+	Value string `xml:"Value"`
+}
+
+type route53RecordSet struct {
+	Name            string                  `xml:"Name"`
+	Type            string                  `xml:"Type"`
+	SetIdentifier   string                  `xml:"SetIdentifier,omitempty"`
+	GeoLocation     *GeoLocation            `xml:"GeoLocation,omitempty"`
+	TTL             int                     `xml:"TTL,omitempty"`
+	ResourceRecords []route53ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53RecordSets struct {
+	ResourceRecordSets   []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated          bool               `xml:"IsTruncated"`
+	NextRecordName       string             `xml:"NextRecordName"`
+	NextRecordType       string             `xml:"NextRecordType"`
+	NextRecordIdentifier string             `xml:"NextRecordIdentifier"`
+}
+
+type route53HostedZones struct {
+	HostedZones []struct {
+		ID   string `xml:"Id"`
+		Name string `xml:"Name"`
+	} `xml:"HostedZones>HostedZone"`
+}
+
+type route53Change struct {
+	Action            string           `xml:"Action"`
+	ResourceRecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Comment string          `xml:"ChangeBatch>Comment,omitempty"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53Error struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	Messages []string `xml:"Messages>Message"`
+}
+
+func NewRoute53(accessKeyID string, secretAccessKey string) *Route53 {
+	return &Route53{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, Transport: NewTransport(Route53RateLimit, 30*time.Second)}
+}
+
+func (r *Route53) Name() string {
+	return "route53"
+}
+
+// LocationID numbers the geolocation of the country, taken from its route53
+// entry or else from its country code. The numbers only hold for the
+// lifetime of the provider. The default location is 0.
+func (r *Route53) LocationID(country Country) int {
+	geo := GeoLocation{CountryCode: country.CC}
+	if country.Route53 != nil {
+		geo = *country.Route53
+	}
+	return r.location(geo)
+}
+
+func (r *Route53) location(geo GeoLocation) int {
+	key := geo.key()
+	if key == "default" {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locations == nil {
+		r.locations = make(map[string]int)
+	}
+	id, ok := r.locations[key]
+	if !ok {
+		id = len(r.locations) + 1
+		r.locations[key] = id
+	}
+	return id
+}
+
+// ListRecords reads every geolocation record set of the domain and returns
+// one record per answer. It also resets the queued changes of the domain.
+func (r *Route53) ListRecords(domain string) ([]Record, error) {
+	zoneID, err := r.zoneID(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	zone := &route53Zone{id: zoneID, current: make(map[string]route53RecordSet), desired: make(map[string]route53RecordSet)}
+	query := url.Values{}
+	for {
+		bodyBytes, err := r.do("list", "GET", "/2013-04-01/hostedzone/"+zoneID+"/rrset", query, nil, true)
+		if err != nil {
+			return nil, err
+		}
+
+		var page route53RecordSets
+		if err := xml.Unmarshal(bodyBytes, &page); err != nil {
+			return nil, &ProviderError{Provider: r.Name(), Operation: "list", Kind: ErrProvider, Message: "invalid response", Err: err}
+		}
+		for _, set := range page.ResourceRecordSets {
+			// Alias and non geolocation record sets are left alone
+			if set.GeoLocation == nil || len(set.ResourceRecords) == 0 {
+				continue
+			}
+			zone.current[route53SetKey(set)] = set
+			zone.desired[route53SetKey(set)] = set
+		}
+
+		if !page.IsTruncated {
+			break
+		}
+		query = url.Values{}
+		query.Set("name", page.NextRecordName)
+		query.Set("type", page.NextRecordType)
+		if page.NextRecordIdentifier != "" {
+			query.Set("identifier", page.NextRecordIdentifier)
+		}
+	}
+
+	r.mu.Lock()
+	if r.zones == nil {
+		r.zones = make(map[string]*route53Zone)
+	}
+	r.zones[domain] = zone
+	r.mu.Unlock()
+
+	var records []Record
+	for key, set := range zone.current {
+		for _, rr := range set.ResourceRecords {
+			records = append(records, Record{
+				ID:       key + "|" + rr.Value,
+				Host:     route53Host(set.Name, domain),
+				Type:     set.Type,
+				TTL:      set.TTL,
+				Value:    rr.Value,
+				Location: r.location(*set.GeoLocation),
+			})
+		}
+	}
+	return records, nil
+}
+
+// CreateRecord queues an answer for the record set of its host, type and
+// geolocation.
+func (r *Route53) CreateRecord(domain string, record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	zone, err := r.zone(ActionCreate, domain)
+	if err != nil {
+		return err
+	}
+	return r.add(zone, domain, record)
+}
+
+// UpdateRecord queues replacing the answer named by the record ID.
+func (r *Route53) UpdateRecord(domain string, record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	zone, err := r.zone(ActionUpdate, domain)
+	if err != nil {
+		return err
+	}
+	if err := r.remove(zone, ActionUpdate, record.ID); err != nil {
+		return err
+	}
+	return r.add(zone, domain, record)
+}
+
+// DeleteRecord queues removing the answer named by the record ID.
+func (r *Route53) DeleteRecord(domain string, record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	zone, err := r.zone(ActionDelete, domain)
+	if err != nil {
+		return err
+	}
+	return r.remove(zone, ActionDelete, record.ID)
+}
+
+// Commit sends the queued changes of the domain. Record sets left without
+// answers are deleted, new and changed ones upserted.
+func (r *Route53) Commit(domain string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	zone, err := r.zone("commit", domain)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for key := range zone.current {
+		keys[key] = true
+	}
+	for key := range zone.desired {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []route53Change
+	for _, key := range sorted {
+		current, listed := zone.current[key]
+		desired := zone.desired[key]
+		switch {
+		case len(desired.ResourceRecords) == 0 && listed:
+			changes = append(changes, route53Change{Action: "DELETE", ResourceRecordSet: current})
+		case len(desired.ResourceRecords) > 0 && !route53SetEqual(current, desired):
+			changes = append(changes, route53Change{Action: "UPSERT", ResourceRecordSet: desired})
+		}
+	}
+
+	for start := 0; start < len(changes); start += route53BatchSize {
+		end := start + route53BatchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+		batch := route53ChangeRequest{Comment: "geodns-manager", Changes: changes[start:end]}
+		body, err := xml.Marshal(batch)
+		if err != nil {
+			return &ProviderError{Provider: r.Name(), Operation: "commit", Kind: ErrProvider, Err: err}
+		}
+		if _, err := r.do("commit", "POST", "/2013-04-01/hostedzone/"+zone.id+"/rrset/", nil, body, false); err != nil {
+			return err
+		}
+
+		for _, change := range batch.Changes {
+			key := route53SetKey(change.ResourceRecordSet)
+			if change.Action == "DELETE" {
+				delete(zone.current, key)
+			} else {
+				zone.current[key] = change.ResourceRecordSet
+			}
+		}
+	}
+	return nil
+}
+
+// zone returns the listed zone of a domain. The caller holds r.mu.
+func (r *Route53) zone(operation string, domain string) (*route53Zone, error) {
+	zone, ok := r.zones[domain]
+	if !ok {
+		return nil, &ProviderError{Provider: r.Name(), Operation: operation, Kind: ErrProvider, Message: "records of " + domain + " were not listed"}
+	}
+	return zone, nil
+}
+
+// add puts the answer of a record into its record set, creating the set
+// when needed. The caller holds r.mu.
+func (r *Route53) add(zone *route53Zone, domain string, record Record) error {
+	var geo *GeoLocation
+	for key, id := range r.locations {
+		if id == record.Location {
+			geo = parseGeoKey(key)
+		}
+	}
+	if geo == nil {
+		if record.Location != 0 {
+			return &ProviderError{Provider: r.Name(), Operation: ActionCreate, Kind: ErrValidation, Message: fmt.Sprintf("unknown location %d", record.Location)}
+		}
+		geo = &GeoLocation{CountryCode: "*"}
+	}
+
+	name := route53Name(record.Host, domain)
+	var set route53RecordSet
+	var found bool
+	for _, existing := range zone.desired {
+		if existing.Name == name && existing.Type == record.Type && existing.GeoLocation.key() == geo.key() {
+			set, found = existing, true
+			break
+		}
+	}
+	if !found {
+		host := record.Host
+		if host == "" {
+			host = "@"
+		}
+		set = route53RecordSet{Name: name, Type: record.Type, SetIdentifier: host + "-" + geo.key(), GeoLocation: geo}
+	}
+
+	set.TTL = record.TTL
+	set.ResourceRecords = append([]route53ResourceRecord{}, set.ResourceRecords...)
+	duplicate := false
+	for _, rr := range set.ResourceRecords {
+		duplicate = duplicate || rr.Value == record.Value
+	}
+	if !duplicate {
+		set.ResourceRecords = append(set.ResourceRecords, route53ResourceRecord{Value: record.Value})
+	}
+	zone.desired[route53SetKey(set)] = set
+	return nil
+}
+
+// remove takes the answer named by a record ID out of its record set. The
+// caller holds r.mu.
+func (r *Route53) remove(zone *route53Zone, operation string, id string) error {
+	split := strings.LastIndex(id, "|")
+	if split < 0 {
+		return &ProviderError{Provider: r.Name(), Operation: operation, Kind: ErrValidation, Message: "invalid record id " + id}
+	}
+	key, value := id[:split], id[split+1:]
+
+	set, ok := zone.desired[key]
+	if !ok {
+		return &ProviderError{Provider: r.Name(), Operation: operation, Kind: ErrValidation, Message: "unknown record set " + key}
+	}
+
+	var kept []route53ResourceRecord
+	for _, rr := range set.ResourceRecords {
+		if rr.Value != value {
+			kept = append(kept, rr)
+		}
+	}
+	set.ResourceRecords = kept
+	zone.desired[key] = set
+	return nil
+}
+
+// zoneID returns the hosted zone ID of a domain, looking it up by name.
+func (r *Route53) zoneID(domain string) (string, error) {
+	if id, ok := r.ZoneIDs[domain]; ok {
+		return id, nil
+	}
+
+	query := url.Values{}
+	query.Set("dnsname", domain)
+	bodyBytes, err := r.do("list", "GET", "/2013-04-01/hostedzonesbyname", query, nil, true)
+	if err != nil {
+		return "", err
+	}
+
+	var zones route53HostedZones
+	if err := xml.Unmarshal(bodyBytes, &zones); err != nil {
+		return "", &ProviderError{Provider: r.Name(), Operation: "list", Kind: ErrProvider, Message: "invalid response", Err: err}
+	}
+	for _, zone := range zones.HostedZones {
+		if strings.TrimSuffix(zone.Name, ".") == domain {
+			id := strings.TrimPrefix(zone.ID, "/hostedzone/")
+			r.mu.Lock()
+			if r.ZoneIDs == nil {
+				r.ZoneIDs = make(map[string]string)
+			}
+			r.ZoneIDs[domain] = id
+			r.mu.Unlock()
+			return id, nil
+		}
+	}
+	return "", &ProviderError{Provider: r.Name(), Operation: "list", Kind: ErrValidation, Message: "no hosted zone for " + domain}
+}
+
+// do sends a signed request and returns the response body.
+func (r *Route53) do(operation string, method string, path string, query url.Values, body []byte, idempotent bool) ([]byte, error) {
+	baseURL := r.BaseURL
+	if baseURL == "" {
+		baseURL = route53URL
+	}
+
+	fail := func(kind error, statusCode int, message string, err error) *ProviderError {
+		return &ProviderError{Provider: r.Name(), Operation: operation, Kind: kind, StatusCode: statusCode, Message: message, Payload: string(body), Err: err}
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = defaultTransport
+	}
+
+	var bodyBytes []byte
+	err := transport.Call(idempotent, func(client *http.Client) error {
+		target := baseURL + path
+		if len(query) > 0 {
+			target += "?" + route53Query(query)
+		}
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		if err != nil {
+			return fail(ErrProvider, 0, "", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/xml")
+		}
+		r.sign(req, body, time.Now().UTC())
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fail(ErrNetwork, 0, "", err)
+		}
+		defer resp.Body.Close()
+
+		bodyBytes, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return fail(ErrNetwork, resp.StatusCode, "", err)
+		}
+		if resp.StatusCode != 200 {
+			kind, message := route53ErrorKind(resp.StatusCode, bodyBytes)
+			perr := fail(kind, resp.StatusCode, message, nil)
+			perr.RetryAfter = retryAfter(resp.Header)
+			return perr
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bodyBytes, nil
+}
+
+// route53ErrorKind classifies an error response by its AWS error code.
+func route53ErrorKind(statusCode int, body []byte) (error, string) {
+	var parsed route53Error
+	if xml.Unmarshal(body, &parsed) != nil {
+		return statusKind(statusCode), errorMessage(body)
+	}
+
+	message := parsed.Error.Message
+	if len(parsed.Messages) > 0 {
+		message = strings.Join(parsed.Messages, "; ")
+	}
+	switch parsed.Error.Code {
+	case "Throttling", "PriorRequestNotComplete":
+		return ErrRateLimit, message
+	case "InvalidClientTokenId", "SignatureDoesNotMatch", "AccessDenied", "IncompleteSignature":
+		return ErrAuth, message
+	}
+	return statusKind(statusCode), message
+}
+
+// sign adds an AWS Signature Version 4 to the request.
+func (r *Route53) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" + "x-amz-date:" + amzDate + "\n",
+		"host;x-amz-date",
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + route53Region + "/route53/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + r.SecretAccessKey)
+	for _, part := range []string{date, route53Region, "route53", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-date, Signature=%s",
+		r.AccessKeyID, scope, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// route53Query encodes a query sorted by key with spaces as %20, as the
+// signature requires.
+func route53Query(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func route53SetKey(set route53RecordSet) string {
+	return set.Name + "|" + set.Type + "|" + set.SetIdentifier
+}
+
+func route53SetEqual(a route53RecordSet, b route53RecordSet) bool {
+	if a.TTL != b.TTL || len(a.ResourceRecords) != len(b.ResourceRecords) {
+		return false
+	}
+	values := make(map[string]bool)
+	for _, rr := range a.ResourceRecords {
+		values[rr.Value] = true
+	}
+	for _, rr := range b.ResourceRecords {
+		if !values[rr.Value] {
+			return false
+		}
+	}
+	return true
+}
+
+// route53Name returns the fully qualified name of a host, "" being the apex.
+func route53Name(host string, domain string) string {
+	if host == "" || host == "@" {
+		return domain + "."
+	}
+	return host + "." + domain + "."
+}
+
+// route53Host returns the host of a fully qualified record set name.
+func route53Host(name string, domain string) string {
+	name = strings.TrimSuffix(name, ".")
+	if name == domain {
+		return ""
+	}
+	return strings.TrimSuffix(name, "."+domain)
+}
+
+func parseGeoKey(key string) *GeoLocation {
+	switch {
+	case strings.HasPrefix(key, "continent-"):
+		return &GeoLocation{ContinentCode: strings.TrimPrefix(key, "continent-")}
+	case key == "default":
+		return &GeoLocation{CountryCode: "*"}
+	case strings.Contains(key, "-"):
+		parts := strings.SplitN(key, "-", 2)
+		return &GeoLocation{CountryCode: parts[0], SubdivisionCode: parts[1]}
+	default:
+		return &GeoLocation{CountryCode: key}
+	}
+}
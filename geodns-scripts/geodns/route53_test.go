@@ -0,0 +1,333 @@
+package geodns
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRoute53Sign(t *testing.T) {
+	r := NewRoute53("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY")
+	req, err := http.NewRequest("GET", "https://route53.amazonaws.com/2013-04-01/hostedzone/Z123/rrset?name=rpc.ibp.network.&type=A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.sign(req, nil, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	if got := req.Header.Get("X-Amz-Date"); got != "20240501T120000Z" {
+		t.Errorf("X-Amz-Date = %s", got)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240501/us-east-1/route53/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=071d3e9b4b313980656fa55df5891ab7d27e31b7cf672c7aa852ecd3ed30e96c"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
+
+var route53AuthRe = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKID/\d{8}/us-east-1/route53/aws4_request, SignedHeaders=host;x-amz-date, Signature=[0-9a-f]{64}$`)
+
+// fakeRoute53 serves one hosted zone, listing its record sets in pages and
+// applying change batches the way Route53 does.
+type fakeRoute53 struct {
+	t        *testing.T
+	pageSize int
+
+	mu      sync.Mutex
+	sets    map[string]route53RecordSet
+	lists   int
+	batches [][]route53Change
+}
+
+type fakeRoute53Page struct {
+	XMLName              xml.Name           `xml:"ListResourceRecordSetsResponse"`
+	ResourceRecordSets   []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated          bool               `xml:"IsTruncated"`
+	NextRecordName       string             `xml:"NextRecordName,omitempty"`
+	NextRecordType       string             `xml:"NextRecordType,omitempty"`
+	NextRecordIdentifier string             `xml:"NextRecordIdentifier,omitempty"`
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !route53AuthRe.MatchString(r.Header.Get("Authorization")) {
+		f.t.Errorf("%s %s: unexpected Authorization %q", r.Method, r.URL, r.Header.Get("Authorization"))
+	}
+	if !regexp.MustCompile(`^\d{8}T\d{6}Z$`).MatchString(r.Header.Get("X-Amz-Date")) {
+		f.t.Errorf("%s %s: unexpected X-Amz-Date %q", r.Method, r.URL, r.Header.Get("X-Amz-Date"))
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/2013-04-01/hostedzonesbyname":
+		fmt.Fprintf(w, `<ListHostedZonesByNameResponse><HostedZones>`+
+			`<HostedZone><Id>/hostedzone/ZOTHER</Id><Name>other.network.</Name></HostedZone>`+
+			`<HostedZone><Id>/hostedzone/Z1</Id><Name>%s.</Name></HostedZone>`+
+			`</HostedZones></ListHostedZonesByNameResponse>`, r.URL.Query().Get("dnsname"))
+
+	case r.Method == "GET" && r.URL.Path == "/2013-04-01/hostedzone/Z1/rrset":
+		f.lists++
+		keys := f.sortedKeys()
+		query := r.URL.Query()
+		start := 0
+		if query.Get("name") != "" {
+			start = sort.SearchStrings(keys, query.Get("name")+"|"+query.Get("type")+"|"+query.Get("identifier"))
+		}
+
+		var page fakeRoute53Page
+		for i := start; i < len(keys) && i < start+f.pageSize; i++ {
+			page.ResourceRecordSets = append(page.ResourceRecordSets, f.sets[keys[i]])
+		}
+		if next := start + f.pageSize; next < len(keys) {
+			set := f.sets[keys[next]]
+			page.IsTruncated = true
+			page.NextRecordName, page.NextRecordType, page.NextRecordIdentifier = set.Name, set.Type, set.SetIdentifier
+		}
+		body, _ := xml.Marshal(page)
+		w.Write(body)
+
+	case r.Method == "POST" && r.URL.Path == "/2013-04-01/hostedzone/Z1/rrset/":
+		if r.Header.Get("Content-Type") != "application/xml" {
+			f.t.Errorf("unexpected Content-Type %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var request route53ChangeRequest
+		if err := xml.Unmarshal(body, &request); err != nil {
+			f.fail(w, "MalformedInput", err.Error())
+			return
+		}
+		if len(request.Changes) == 0 || len(request.Changes) > route53BatchSize {
+			f.fail(w, "InvalidChangeBatch", fmt.Sprintf("%d changes", len(request.Changes)))
+			return
+		}
+		for _, change := range request.Changes {
+			key := route53SetKey(change.ResourceRecordSet)
+			switch change.Action {
+			case "DELETE":
+				// Deletes have to match the record set exactly
+				current, ok := f.sets[key]
+				if !ok || !route53SetEqual(current, change.ResourceRecordSet) {
+					f.fail(w, "InvalidChangeBatch", "record set to delete does not match "+key)
+					return
+				}
+				delete(f.sets, key)
+			case "UPSERT":
+				f.sets[key] = change.ResourceRecordSet
+			default:
+				f.fail(w, "InvalidChangeBatch", "unexpected action "+change.Action)
+				return
+			}
+		}
+		f.batches = append(f.batches, request.Changes)
+		fmt.Fprint(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeRoute53) fail(w http.ResponseWriter, code string, message string) {
+	f.t.Errorf("%s: %s", code, message)
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `<ErrorResponse><Error><Code>%s</Code><Message>%s</Message></Error></ErrorResponse>`, code, message)
+}
+
+func (f *fakeRoute53) sortedKeys() []string {
+	var keys []string
+	for key := range f.sets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func geoSet(country string, values ...string) route53RecordSet {
+	set := route53RecordSet{
+		Name:          "rpc.ibp.network.",
+		Type:          "A",
+		SetIdentifier: "rpc-" + country,
+		GeoLocation:   &GeoLocation{CountryCode: country},
+		TTL:           60,
+	}
+	if country == "*" {
+		set.SetIdentifier = "rpc-default"
+	}
+	for _, value := range values {
+		set.ResourceRecords = append(set.ResourceRecords, route53ResourceRecord{Value: value})
+	}
+	return set
+}
+
+func TestRoute53ListAndCommit(t *testing.T) {
+	fake := &fakeRoute53{t: t, pageSize: 2, sets: make(map[string]route53RecordSet)}
+	for _, set := range []route53RecordSet{
+		{Name: "ibp.network.", Type: "NS", TTL: 172800, ResourceRecords: []route53ResourceRecord{{Value: "ns-1.awsdns-00.com."}}},
+		geoSet("DE", "192.0.2.1", "192.0.2.2"),
+		geoSet("FR", "192.0.2.3"),
+		geoSet("GB", "192.0.2.4"),
+		geoSet("*", "192.0.2.5"),
+	} {
+		fake.sets[route53SetKey(set)] = set
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	r := NewRoute53("AKID", "secret")
+	r.BaseURL = server.URL
+	r.Transport = NewTransport(0, 5*time.Second)
+
+	records, err := r.ListRecords("ibp.network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.lists != 3 {
+		t.Errorf("listed %d pages, want 3", fake.lists)
+	}
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5 answers of geolocation sets: %v", len(records), records)
+	}
+
+	de := r.LocationID(Country{CC: "DE"})
+	fr := r.LocationID(Country{CC: "FR"})
+	byValue := make(map[string]Record)
+	for _, record := range records {
+		if record.Host != "rpc" || record.Type != "A" || record.TTL != 60 {
+			t.Errorf("unexpected record %+v", record)
+		}
+		byValue[record.Value] = record
+	}
+	if byValue["192.0.2.1"].Location != de || byValue["192.0.2.3"].Location != fr || byValue["192.0.2.5"].Location != 0 {
+		t.Errorf("unexpected locations %v", records)
+	}
+
+	// Swap one answer of a set, empty another and add enough new sets to
+	// need two change batches
+	write := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(r.DeleteRecord("ibp.network", byValue["192.0.2.1"]))
+	write(r.CreateRecord("ibp.network", Record{Host: "rpc", Type: "A", TTL: 60, Value: "192.0.2.6", Location: de}))
+	write(r.DeleteRecord("ibp.network", byValue["192.0.2.3"]))
+	for i := 0; i < 120; i++ {
+		location := r.LocationID(Country{Route53: &GeoLocation{CountryCode: "US", SubdivisionCode: fmt.Sprintf("S%03d", i)}})
+		write(r.CreateRecord("ibp.network", Record{Host: "rpc", Type: "A", TTL: 60, Value: "192.0.2.7", Location: location}))
+	}
+	if len(fake.batches) != 0 {
+		t.Fatal("changes sent before Commit")
+	}
+
+	write(r.Commit("ibp.network"))
+	if len(fake.batches) != 2 || len(fake.batches[0]) != 100 || len(fake.batches[1]) != 22 {
+		var sizes []int
+		for _, batch := range fake.batches {
+			sizes = append(sizes, len(batch))
+		}
+		t.Fatalf("sent batches of %v changes, want [100 22]", sizes)
+	}
+
+	changes := make(map[string]route53Change)
+	for _, batch := range fake.batches {
+		for _, change := range batch {
+			changes[change.ResourceRecordSet.SetIdentifier] = change
+		}
+	}
+	if change := changes["rpc-DE"]; change.Action != "UPSERT" || !route53SetEqual(change.ResourceRecordSet, geoSet("DE", "192.0.2.2", "192.0.2.6")) {
+		t.Errorf("DE change = %+v, want an UPSERT of the kept and the added answer", change)
+	}
+	if change := changes["rpc-FR"]; change.Action != "DELETE" || !route53SetEqual(change.ResourceRecordSet, geoSet("FR", "192.0.2.3")) {
+		t.Errorf("FR change = %+v, want a DELETE of the listed set", change)
+	}
+	for _, unchanged := range []string{"rpc-GB", "rpc-default"} {
+		if change, ok := changes[unchanged]; ok {
+			t.Errorf("unexpected change of an unchanged set: %+v", change)
+		}
+	}
+	if change := changes["rpc-US-S042"]; change.Action != "UPSERT" || change.ResourceRecordSet.GeoLocation.SubdivisionCode != "S042" {
+		t.Errorf("US-S042 change = %+v", change)
+	}
+
+	// Committing again sends nothing
+	write(r.Commit("ibp.network"))
+	if len(fake.batches) != 2 {
+		t.Errorf("sent %d batches after a second commit, want 2", len(fake.batches))
+	}
+
+	records, err = r.ListRecords("ibp.network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for _, record := range records {
+		if record.Value != "192.0.2.7" {
+			values = append(values, record.Value)
+		}
+	}
+	sort.Strings(values)
+	if got := strings.Join(values, " "); len(records) != 124 || got != "192.0.2.2 192.0.2.4 192.0.2.5 192.0.2.6" {
+		t.Errorf("got %d records with %s after the commit", len(records), got)
+	}
+}
+
+func TestRoute53DefaultLocation(t *testing.T) {
+	fake := &fakeRoute53{t: t, pageSize: 100, sets: make(map[string]route53RecordSet)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	r := NewRoute53("AKID", "secret")
+	r.BaseURL = server.URL
+	r.Transport = NewTransport(0, 5*time.Second)
+
+	alpha := Member{ID: "alpha", ServicesAddress: "192.0.2.1"}
+	beta := Member{ID: "beta", ServicesAddress: "192.0.2.2"}
+	assignments := []Assignment{
+		{Country: Country{Name: "Germany", CC: "DE", Route53: &GeoLocation{CountryCode: "DE"}}, Candidate: Candidate{Member: alpha}},
+		{Country: Country{Name: "Continent - Europe", CC: "DE", Route53: &GeoLocation{ContinentCode: "EU"}}, Candidate: Candidate{Member: alpha}},
+		{Country: Country{Name: "Default - Other locations", CC: "DE", Route53: &GeoLocation{CountryCode: "*"}}, Candidate: Candidate{Member: beta}},
+	}
+
+	plan, err := NewPlan(r, "ibp.network", "rpc", assignments, PlanOptions{TTL: 60, Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(r, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []route53RecordSet{
+		geoSet("DE", "192.0.2.1"),
+		geoSet("*", "192.0.2.2"),
+		{Name: "rpc.ibp.network.", Type: "A", SetIdentifier: "rpc-continent-EU", GeoLocation: &GeoLocation{ContinentCode: "EU"}, TTL: 60,
+			ResourceRecords: []route53ResourceRecord{{Value: "192.0.2.1"}}},
+	} {
+		if got := fake.sets[route53SetKey(want)]; !route53SetEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+
+	// The default location moves like any other location
+	assignments[2].Candidate = Candidate{Member: alpha}
+	plan, err = NewPlan(r, "ibp.network", "rpc", assignments, PlanOptions{TTL: 60, Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != ActionUpdate || plan.Changes[0].Location != 0 {
+		t.Fatalf("unexpected plan %+v", plan.Changes)
+	}
+	if err := Apply(r, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.sets[route53SetKey(geoSet("*"))]; !route53SetEqual(got, geoSet("*", "192.0.2.1")) {
+		t.Errorf("default set %+v after the move", got)
+	}
+}
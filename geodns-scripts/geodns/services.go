@@ -0,0 +1,103 @@
+package geodns
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	ServiceType   string            `json:"service_type"`
+	LevelRequired string            `json:"level_required"`
+	Endpoints     map[string]string `json:"endpoints"`
+	Members       []string          `json:"members"`
+}
+
+type Services struct {
+	Services map[string]Service `json:"services"`
+}
+
+// LoadServices reads services.json.
+func LoadServices(filePath string) (Services, error) {
+	var services Services
+	if err := loadJSON(filePath, &services); err != nil {
+		return services, err
+	}
+	return services, nil
+}
+
+// Names returns the service names in sorted order.
+func (s Services) Names() []string {
+	var names []string
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Level returns the level required to serve the service.
+func (s Service) Level() int {
+	level, _ := strconv.Atoi(s.LevelRequired)
+	return level
+}
+
+// EligibleMembers returns the members on the service allow-list that are
+// eligible at the required level.
+func (s Service) EligibleMembers(members Members) []Member {
+	return s.EligibleMembersAt(members, EligibilityOptions{})
+}
+
+// EligibleMembersAt returns the members on the service allow-list that are
+// eligible at the required level at opts.Now after their probation.
+func (s Service) EligibleMembersAt(members Members, opts EligibilityOptions) []Member {
+	var validMembers []Member
+	for _, member := range EligibleMembersAt(members, s.Level(), opts) {
+		if s.allows(member.ID) {
+			validMembers = append(validMembers, member)
+		}
+	}
+	return validMembers
+}
+
+// UpcomingEligibility returns the eligibility changes of the members on
+// the service allow-list within horizon.
+func (s Service) UpcomingEligibility(members Members, opts EligibilityOptions, horizon time.Duration) []EligibilityChange {
+	var changes []EligibilityChange
+	for _, change := range UpcomingEligibility(members, s.Level(), opts, horizon) {
+		if s.allows(change.Member) {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func (s Service) allows(id string) bool {
+	for _, member := range s.Members {
+		if member == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitServiceName splits a service name such as rpc.ibp.network into the
+// record host and the zone it lives in.
+func SplitServiceName(name string) (string, string) {
+	host, domain, found := strings.Cut(name, ".")
+	if !found {
+		return "", name
+	}
+	return host, domain
+}
+
+// Chains returns the chains served by the service in sorted order.
+func (s Service) Chains() []string {
+	var chains []string
+	for chain := range s.Endpoints {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+	return chains
+}
@@ -0,0 +1,248 @@
+package geodns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Source is where a configuration file such as members.json is read from.
+type Source interface {
+	Fetch() ([]byte, error)
+	String() string
+}
+
+// CacheDir holds copies of files fetched over HTTP. When empty, files are
+// fetched without caching.
+var CacheDir = defaultCacheDir()
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "geodns-manager")
+}
+
+// SourceTimeout bounds fetching a file over HTTP, so that a stalled server
+// can't hang a sync.
+var SourceTimeout = 30 * time.Second
+
+// NewSource parses a location, which is one of
+//
+//	./members.json                                 a local file
+//	https://raw.githubusercontent.com/.../x.json   an HTTP(S) URL
+//	git:/srv/ibp-config@main:members.json          a file at a ref of a local git checkout
+func NewSource(location string) Source {
+	switch {
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		return &HTTPSource{URL: location, CacheDir: CacheDir}
+	case strings.HasPrefix(location, "git:"):
+		return newGitSource(strings.TrimPrefix(location, "git:"))
+	default:
+		return FileSource(location)
+	}
+}
+
+// ReadSource fetches the contents of a location, see NewSource.
+func ReadSource(location string) ([]byte, error) {
+	return NewSource(location).Fetch()
+}
+
+// resolveSource returns the location of a file referenced from the file at
+// base, resolving relative references next to it in the same kind of
+// source.
+func resolveSource(base string, location string) string {
+	if location == "" || strings.Contains(location, "://") || strings.HasPrefix(location, "git:") || filepath.IsAbs(location) {
+		return location
+	}
+
+	switch source := NewSource(base).(type) {
+	case *HTTPSource:
+		baseURL, err := url.Parse(source.URL)
+		if err != nil {
+			return location
+		}
+		ref, err := url.Parse(location)
+		if err != nil {
+			return location
+		}
+		return baseURL.ResolveReference(ref).String()
+	case *GitSource:
+		resolved := *source
+		resolved.Path = path.Join(path.Dir(source.Path), filepath.ToSlash(location))
+		return resolved.String()
+	default:
+		return filepath.Join(filepath.Dir(base), location)
+	}
+}
+
+// FileSource is a file on the local disk.
+type FileSource string
+
+func (f FileSource) Fetch() ([]byte, error) {
+	return ioutil.ReadFile(string(f))
+}
+
+func (f FileSource) String() string {
+	return string(f)
+}
+
+// GitSource is a file at a ref of a local git checkout, read without
+// touching the working tree.
+type GitSource struct {
+	Dir  string
+	Ref  string
+	Path string
+}
+
+func newGitSource(location string) *GitSource {
+	source := &GitSource{Ref: "HEAD"}
+	repo, path, _ := strings.Cut(location, ":")
+	source.Path = path
+	if dir, ref, found := strings.Cut(repo, "@"); found {
+		source.Dir = dir
+		source.Ref = ref
+	} else {
+		source.Dir = repo
+	}
+	return source
+}
+
+func (g *GitSource) Fetch() ([]byte, error) {
+	if g.Path == "" {
+		return nil, fmt.Errorf("%s: missing file path", g)
+	}
+	// A ref starting with a dash would be read by git as an option
+	if g.Ref == "" || strings.HasPrefix(g.Ref, "-") || strings.IndexFunc(g.Ref, unicode.IsSpace) >= 0 {
+		return nil, fmt.Errorf("%s: invalid ref %q", g, g.Ref)
+	}
+	output, err := exec.Command("git", "-C", g.Dir, "show", g.Ref+":"+g.Path).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s: %s", g, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("%s: %v", g, err)
+	}
+	return output, nil
+}
+
+func (g *GitSource) String() string {
+	return "git:" + g.Dir + "@" + g.Ref + ":" + g.Path
+}
+
+// HTTPSource is a file served over HTTP(S). Responses are cached with their
+// ETag and Last-Modified headers so that unchanged files are not downloaded
+// again, and the cached copy is used when the server can't be reached.
+type HTTPSource struct {
+	URL      string
+	CacheDir string
+	Client   *http.Client
+}
+
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+func (h *HTTPSource) String() string {
+	return h.URL
+}
+
+func (h *HTTPSource) cachePaths() (string, string) {
+	sum := sha256.Sum256([]byte(h.URL))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(h.CacheDir, name), filepath.Join(h.CacheDir, name+".json")
+}
+
+func (h *HTTPSource) Fetch() ([]byte, error) {
+	var cached []byte
+	var meta cacheMeta
+	var bodyPath, metaPath string
+	if h.CacheDir != "" {
+		bodyPath, metaPath = h.cachePaths()
+		if body, err := ioutil.ReadFile(bodyPath); err == nil {
+			if metaBytes, err := ioutil.ReadFile(metaPath); err == nil && json.Unmarshal(metaBytes, &meta) == nil {
+				cached = body
+			}
+		}
+	}
+
+	req, err := http.NewRequest("GET", h.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: SourceTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if cached != nil {
+			fmt.Printf("Failed to fetch %s, using cached copy: %v\n", h.URL, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, nil
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode >= 500 && cached != nil:
+		fmt.Printf("Failed to fetch %s, using cached copy: %s\n", h.URL, resp.Status)
+		return cached, nil
+	default:
+		return nil, fmt.Errorf("failed to fetch %s: %s", h.URL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.CacheDir != "" {
+		meta = cacheMeta{URL: h.URL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if err := h.store(bodyPath, metaPath, body, meta); err != nil {
+			fmt.Printf("Failed to cache %s: %v\n", h.URL, err)
+		}
+	}
+	return body, nil
+}
+
+func (h *HTTPSource) store(bodyPath string, metaPath string, body []byte, meta cacheMeta) error {
+	if err := os.MkdirAll(h.CacheDir, 0755); err != nil {
+		return err
+	}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(bodyPath, body, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(metaPath, metaBytes, 0644)
+}
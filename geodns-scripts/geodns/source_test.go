@@ -0,0 +1,223 @@
+package geodns
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPSourceETag(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"members":{}}`))
+	}))
+	defer server.Close()
+
+	source := &HTTPSource{URL: server.URL + "/members.json", CacheDir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		body, err := source.Fetch()
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if string(body) != `{"members":{}}` {
+			t.Errorf("fetch %d: got %q", i, body)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("downloaded %d times, want 1", downloads)
+	}
+}
+
+func TestHTTPSourceLastModified(t *testing.T) {
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Errorf("unexpected If-None-Match without an ETag")
+		}
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte("cached"))
+	}))
+	defer server.Close()
+
+	source := &HTTPSource{URL: server.URL, CacheDir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		body, err := source.Fetch()
+		if err != nil || string(body) != "cached" {
+			t.Fatalf("fetch %d: got %q, %v", i, body, err)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("downloaded %d times, want 1", downloads)
+	}
+}
+
+func TestHTTPSourceServerErrorUsesCache(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("first"))
+	}))
+	defer server.Close()
+
+	source := &HTTPSource{URL: server.URL, CacheDir: t.TempDir()}
+	if _, err := source.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+
+	failing = true
+	body, err := source.Fetch()
+	if err != nil {
+		t.Fatalf("expected the cached copy, got %v", err)
+	}
+	if string(body) != "first" {
+		t.Errorf("got %q, want the cached copy", body)
+	}
+
+	// Without a cache the failure is returned
+	source.CacheDir = ""
+	if _, err := source.Fetch(); err == nil {
+		t.Error("expected an error without a cache")
+	}
+}
+
+func TestHTTPSourceNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	source := &HTTPSource{URL: server.URL + "/missing.json", CacheDir: t.TempDir()}
+	_, err := source.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("got %v, want a 404 error", err)
+	}
+	if entries, _ := os.ReadDir(source.CacheDir); len(entries) != 0 {
+		t.Errorf("cached %d files for a missing file", len(entries))
+	}
+}
+
+func TestHTTPSourceTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	defer func(timeout time.Duration) { SourceTimeout = timeout }(SourceTimeout)
+	SourceTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := (&HTTPSource{URL: server.URL}).Fetch(); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetch took %v", elapsed)
+	}
+}
+
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	write := func(name string, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("config/members.json", "released")
+	git("add", ".")
+	git("commit", "-q", "-m", "release")
+	git("tag", "v1")
+	write("config/members.json", "next")
+	git("commit", "-q", "-am", "next")
+	write("config/members.json", "uncommitted")
+
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"git:" + dir + "@v1:config/members.json", "released"},
+		{"git:" + dir + ":config/members.json", "next"},
+		{resolveSource("git:"+dir+"@v1:config/capacity.json", "members.json"), "released"},
+	}
+	for _, test := range tests {
+		body, err := ReadSource(test.location)
+		if err != nil {
+			t.Errorf("%s: %v", test.location, err)
+			continue
+		}
+		if string(body) != test.want {
+			t.Errorf("%s: got %q, want %q", test.location, body, test.want)
+		}
+	}
+
+	if _, err := ReadSource("git:" + dir + "@v1:missing.json"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	output := filepath.Join(t.TempDir(), "output")
+	for _, ref := range []string{"--output=" + output, "-p", "v1 --all", "v1\tHEAD", ""} {
+		source := &GitSource{Dir: dir, Ref: ref, Path: "config/members.json"}
+		if _, err := source.Fetch(); err == nil {
+			t.Errorf("accepted ref %q", ref)
+		}
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Error("a ref was passed to git as an option")
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	tests := []struct {
+		base     string
+		location string
+		want     string
+	}{
+		{"capacity.json", "reports/a.json", "reports/a.json"},
+		{"/etc/ibp/capacity.json", "reports/a.json", "/etc/ibp/reports/a.json"},
+		{"/etc/ibp/capacity.json", "/srv/a.json", "/srv/a.json"},
+		{"https://example.com/config/capacity.json", "reports/a.json", "https://example.com/config/reports/a.json"},
+		{"https://example.com/config/capacity.json", "https://other.example/a.json", "https://other.example/a.json"},
+		{"git:/srv/config@main:capacity.json", "reports/a.json", "git:/srv/config@main:reports/a.json"},
+	}
+	for _, test := range tests {
+		if got := resolveSource(test.base, test.location); got != test.want {
+			t.Errorf("resolveSource(%q, %q) = %q, want %q", test.base, test.location, got, test.want)
+		}
+	}
+}
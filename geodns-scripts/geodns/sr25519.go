@@ -0,0 +1,35 @@
+package geodns
+
+import (
+	schnorrkel "github.com/ChainSafe/go-schnorrkel"
+)
+
+// SubstrateSigningContext is the signing context of Substrate sr25519
+// signatures.
+const SubstrateSigningContext = "substrate"
+
+// VerifySr25519 reports whether sig is a valid sr25519 signature of msg in
+// the Substrate signing context by the 32 byte public key.
+func VerifySr25519(publicKey []byte, msg []byte, sig []byte) bool {
+	if len(publicKey) != 32 || len(sig) != 64 {
+		return false
+	}
+
+	var keyBytes [32]byte
+	copy(keyBytes[:], publicKey)
+	key, err := schnorrkel.NewPublicKey(keyBytes)
+	if err != nil {
+		return false
+	}
+
+	// Decoding rejects signatures without the schnorrkel marker bit
+	var sigBytes [64]byte
+	copy(sigBytes[:], sig)
+	var signature schnorrkel.Signature
+	if err := signature.Decode(sigBytes); err != nil {
+		return false
+	}
+
+	ok, err := key.Verify(&signature, schnorrkel.NewSigningContext([]byte(SubstrateSigningContext), msg))
+	return err == nil && ok
+}
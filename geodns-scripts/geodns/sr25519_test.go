@@ -0,0 +1,49 @@
+package geodns
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// flipBit returns a copy of b with one bit inverted.
+func flipBit(b []byte, bit int) []byte {
+	flipped := append([]byte{}, b...)
+	flipped[bit/8] ^= 1 << (bit % 8)
+	return flipped
+}
+
+func TestVerifySr25519(t *testing.T) {
+	// The kusama payment signature of a member, made with polkadot.js over
+	// the payment address wrapped in <Bytes>
+	publicKey := mustHex(t, "70d4121446293f928e59d4f8a5eea096ebe1c2538002fcbf7b7845dd2e19ee6d")
+	msg := []byte("<Bytes>HERTWZK4ZpmuXsEgW3UfL5x27XCdT5fE9x4QRbyNWifafdU</Bytes>")
+	sig := mustHex(t, "52df0775a054197b56d1a6bdf1fdf2365b09f1b6dc784ab5118e36c2bfa7a23f02c782d3c24b3056b82e74cf5550db22d0d457d4dc7f249d5aac2526d7f76681")
+
+	if !VerifySr25519(publicKey, msg, sig) {
+		t.Fatal("valid signature rejected")
+	}
+
+	for _, bit := range []int{0, 255, 256, 500} {
+		if VerifySr25519(publicKey, msg, flipBit(sig, bit)) {
+			t.Errorf("signature with bit %d flipped accepted", bit)
+		}
+	}
+	if VerifySr25519(publicKey, flipBit(msg, 70), sig) {
+		t.Error("signature accepted for a changed message")
+	}
+	if VerifySr25519(flipBit(publicKey, 9), msg, sig) {
+		t.Error("signature accepted for another key")
+	}
+	if VerifySr25519(publicKey, msg[7:len(msg)-8], sig) {
+		t.Error("signature accepted without the <Bytes> wrapping")
+	}
+}
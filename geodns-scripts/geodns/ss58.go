@@ -0,0 +1,68 @@
+package geodns
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// DecodeSS58 decodes a Substrate SS58 address of a 32 byte account and
+// returns its network prefix and public key after checking the checksum.
+func DecodeSS58(address string) (uint16, []byte, error) {
+	data, err := decodeBase58(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("empty address")
+	}
+
+	// Prefixes up to 63 take one byte, larger ones two
+	var prefix uint16
+	prefixLen := 1
+	switch {
+	case data[0] < 64:
+		prefix = uint16(data[0])
+	case data[0] < 128 && len(data) > 1:
+		lower := (data[0] << 2) | (data[1] >> 6)
+		upper := data[1] & 0x3f
+		prefix = uint16(lower) | uint16(upper)<<8
+		prefixLen = 2
+	default:
+		return 0, nil, fmt.Errorf("invalid address prefix")
+	}
+
+	if len(data) != prefixLen+32+2 {
+		return 0, nil, fmt.Errorf("address is %d bytes, expected an account of 32 bytes", len(data))
+	}
+	body := data[:prefixLen+32]
+	checksum := blake2b.Sum512(append([]byte("SS58PRE"), body...))
+	if !bytes.Equal(checksum[:2], data[prefixLen+32:]) {
+		return 0, nil, fmt.Errorf("invalid address checksum")
+	}
+	return prefix, body[prefixLen:], nil
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := bytes.IndexRune([]byte(base58Alphabet), r)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	// Leading ones encode leading zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
@@ -0,0 +1,38 @@
+package geodns
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDecodeSS58(t *testing.T) {
+	tests := []struct {
+		address string
+		prefix  uint16
+		key     string
+	}{
+		{"F8FswEGD1quEyNciuR9KwsragUmqnk7mFLqmAdRdnY9Sdwx", 2, "70d4121446293f928e59d4f8a5eea096ebe1c2538002fcbf7b7845dd2e19ee6d"},
+		{"152QidDC4QrtMCyRGiQmvrNyjntvMg2XouCSdoPSeqUNTvsq", 0, "b204051d55c2c80cdf6e0d3346944e921fc97f0b86a4cc5eb5547982fc475d68"},
+	}
+	for _, test := range tests {
+		prefix, key, err := DecodeSS58(test.address)
+		if err != nil {
+			t.Errorf("%s: %v", test.address, err)
+			continue
+		}
+		if prefix != test.prefix || hex.EncodeToString(key) != test.key {
+			t.Errorf("%s: got prefix %d key %x, want %d %s", test.address, prefix, key, test.prefix, test.key)
+		}
+	}
+
+	for _, address := range []string{
+		"F8FswEGD1quEyNciuR9KwsragUmqnk7mFLqmAdRdnY9Sdwy", // checksum
+		"F8FswEGD1quEyNciuR9KwsragUmqnk7mFLqmAdRdnY9Sdw",  // length
+		"F8FswEGD1quEyNciuR9KwsragUmqnk7mFLqmAdRdnY9Sdw0", // alphabet
+		"",
+	} {
+		if _, _, err := DecodeSS58(address); err == nil {
+			t.Errorf("%q: expected an error", address)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package geodns
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PoolChange is a write to the pool of a member.
+type PoolChange struct {
+	Action     string `json:"action"`
+	Pool       string `json:"pool"`
+	Member     string `json:"member"`
+	OldAddress string `json:"old_address,omitempty"`
+	NewAddress string `json:"new_address"`
+
+	pool CloudflarePool
+}
+
+// SteeringChange is a country or region steered to other members.
+type SteeringChange struct {
+	Country string   `json:"country"`
+	Key     string   `json:"key"`
+	Old     []string `json:"old,omitempty"`
+	New     []string `json:"new"`
+}
+
+// SteeringPlan is the Cloudflare counterpart of Plan: the pools to write
+// and the steering of the load balancer of a host.
+type SteeringPlan struct {
+	Provider     string           `json:"provider"`
+	Domain       string           `json:"domain"`
+	Host         string           `json:"host"`
+	Pools        []PoolChange     `json:"pools"`
+	Steering     []SteeringChange `json:"steering"`
+	LoadBalancer string           `json:"load_balancer,omitempty"`
+
+	zoneID   string
+	existing CloudflareLoadBalancer
+
+	// Steering by member ID, resolved to pool IDs once the pools exist
+	defaults []string
+	country  map[string][]string
+	region   map[string][]string
+}
+
+// CloudflareKey returns the country code or region a countries file entry
+// steers and whether it is a region. Regions come from the
+// cloudflare_region field, country codes from the cloudflare field or else
+// from the country code of the entry.
+func CloudflareKey(country Country) (string, bool) {
+	if country.CloudflareRegion != "" {
+		return country.CloudflareRegion, true
+	}
+	if country.Cloudflare != "" {
+		return country.Cloudflare, false
+	}
+	return country.CC, false
+}
+
+// IsCloudflareRegion reports whether key names a region, not a country.
+func IsCloudflareRegion(key string) bool {
+	for _, region := range CloudflareRegions {
+		if key == region {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSteeringPlan reads the pools and the load balancer of the host and
+// computes the changes needed to steer every country or region of the
+// assignments to its members. Every member gets a pool, and all pools are
+// default pools for locations without steering.
+func NewSteeringPlan(c *Cloudflare, domain string, host string, members []Member, assignments []Assignment, opts PlanOptions) (SteeringPlan, error) {
+	plan := SteeringPlan{Provider: c.Name(), Domain: domain, Host: host, Pools: []PoolChange{}, Steering: []SteeringChange{},
+		country: make(map[string][]string), region: make(map[string][]string)}
+
+	pools, err := c.ListPools()
+	if err != nil {
+		return plan, err
+	}
+	plan.zoneID, err = c.ZoneID(domain)
+	if err != nil {
+		return plan, err
+	}
+	lbs, err := c.ListLoadBalancers(plan.zoneID)
+	if err != nil {
+		return plan, err
+	}
+
+	byName := make(map[string]CloudflarePool)
+	addresses := make(map[string]string)
+	for _, pool := range pools {
+		byName[pool.Name] = pool
+		var origins []string
+		for _, origin := range pool.Origins {
+			origins = append(origins, origin.Address)
+		}
+		addresses[pool.ID] = strings.Join(origins, ",")
+	}
+
+	sorted := append([]Member{}, members...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	for _, member := range sorted {
+		plan.defaults = append(plan.defaults, member.ID)
+
+		desired := CloudflarePool{
+			Name:        c.PoolName(member),
+			Description: member.Name,
+			Enabled:     true,
+			Origins:     []CloudflareOrigin{{Name: member.ID, Address: member.ServicesAddress, Enabled: true, Weight: 1}},
+		}
+		change := PoolChange{Pool: desired.Name, Member: member.ID, NewAddress: member.ServicesAddress, pool: desired}
+		existing, ok := byName[desired.Name]
+		switch {
+		case !ok:
+			change.Action = ActionCreate
+		case !samePool(existing, desired):
+			change.Action = ActionUpdate
+			change.OldAddress = addresses[existing.ID]
+			change.pool.ID = existing.ID
+		default:
+			continue
+		}
+		plan.Pools = append(plan.Pools, change)
+	}
+
+	name := domain
+	if host != "" {
+		name = host + "." + domain
+	}
+	plan.existing = CloudflareLoadBalancer{Name: name}
+	for _, lb := range lbs {
+		if lb.Name == name {
+			plan.existing = lb
+		}
+	}
+
+	for _, assignment := range assignments {
+		key, region := CloudflareKey(assignment.Country)
+		var ids []string
+		var newAddresses []string
+		for _, answer := range assignment.Answers() {
+			ids = append(ids, answer.Member.ID)
+			newAddresses = append(newAddresses, answer.Member.ServicesAddress)
+		}
+
+		current := plan.existing.CountryPools[key]
+		if region {
+			plan.region[key] = ids
+			current = plan.existing.RegionPools[key]
+		} else {
+			plan.country[key] = ids
+		}
+
+		var oldAddresses []string
+		for _, id := range current {
+			oldAddresses = append(oldAddresses, addresses[id])
+		}
+		if strings.Join(oldAddresses, " ") != strings.Join(newAddresses, " ") {
+			plan.Steering = append(plan.Steering, SteeringChange{Country: assignment.Country.Name, Key: key, Old: oldAddresses, New: newAddresses})
+		}
+	}
+
+	switch {
+	case plan.existing.ID == "":
+		plan.LoadBalancer = ActionCreate
+	case len(plan.Pools) > 0 || len(plan.Steering) > 0 || plan.existing.TTL != opts.TTL ||
+		len(plan.existing.CountryPools) != len(plan.country) || len(plan.existing.RegionPools) != len(plan.region) ||
+		plan.existing.SteeringPolicy != "geo":
+		plan.LoadBalancer = ActionUpdate
+	}
+	plan.existing.TTL = opts.TTL
+	return plan, nil
+}
+
+// samePool reports whether an existing pool already is the desired one,
+// ignoring its ID.
+func samePool(existing CloudflarePool, desired CloudflarePool) bool {
+	if existing.Name != desired.Name || existing.Description != desired.Description ||
+		existing.Enabled != desired.Enabled || len(existing.Origins) != len(desired.Origins) {
+		return false
+	}
+	for i, origin := range existing.Origins {
+		if origin != desired.Origins[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a human readable summary of the plan.
+func (plan SteeringPlan) Print(w io.Writer) {
+	lb := plan.LoadBalancer
+	if lb == "" {
+		lb = "unchanged"
+	}
+	fmt.Fprintf(w, "Plan for %s.%s on %s: %d pools to write, %d locations to steer, load balancer %s\n",
+		plan.Host, plan.Domain, plan.Provider, len(plan.Pools), len(plan.Steering), lb)
+
+	for _, change := range plan.Pools {
+		symbol := map[string]string{ActionCreate: "+", ActionUpdate: "~"}[change.Action]
+		fmt.Fprintf(w, "  %s pool %-30s %-15s -> %s\n", symbol, change.Pool, change.OldAddress, change.NewAddress)
+	}
+	for _, change := range plan.Steering {
+		fmt.Fprintf(w, "  ~ %-40s %-31s -> %s\n", fmt.Sprintf("%s (%s)", change.Country, change.Key),
+			strings.Join(change.Old, ","), strings.Join(change.New, ","))
+	}
+}
+
+// ApplySteering writes the pools of the plan and then the load balancer
+// steering to them, adding the outcome of every write to the report.
+func ApplySteering(c *Cloudflare, plan SteeringPlan, report *Report) error {
+	if plan.LoadBalancer == "" {
+		return nil
+	}
+
+	poolIDs := make(map[string]string)
+	pools, err := c.ListPools()
+	if err != nil {
+		report.Add(Result{Provider: c.Name(), Domain: plan.Domain, Host: plan.Host, Action: "list"}, err)
+		return err
+	}
+	for _, pool := range pools {
+		poolIDs[pool.Name] = pool.ID
+	}
+
+	failed := 0
+	for _, change := range plan.Pools {
+		var err error
+		switch change.Action {
+		case ActionCreate:
+			fmt.Printf("Creating pool %s\n", change.Pool)
+			var created CloudflarePool
+			created, err = c.CreatePool(change.pool)
+			if err == nil {
+				poolIDs[created.Name] = created.ID
+			}
+		case ActionUpdate:
+			fmt.Printf("Updating pool %s\n", change.Pool)
+			err = c.UpdatePool(change.pool)
+		}
+		report.Add(Result{Provider: c.Name(), Domain: plan.Domain, Host: plan.Host, Action: change.Action, Country: "pool " + change.Pool}, err)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			failed++
+		}
+	}
+
+	// Members whose pool could not be written are left out of the steering
+	resolve := func(members []string) []string {
+		var ids []string
+		for _, member := range members {
+			if id, ok := poolIDs[c.PoolPrefix+member]; ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	lb := plan.existing
+	lb.SteeringPolicy = "geo"
+	lb.Proxied = false
+	lb.DefaultPools = resolve(plan.defaults)
+	lb.CountryPools = make(map[string][]string)
+	lb.RegionPools = make(map[string][]string)
+	for key, members := range plan.country {
+		if ids := resolve(members); len(ids) > 0 {
+			lb.CountryPools[key] = ids
+		}
+	}
+	for key, members := range plan.region {
+		if ids := resolve(members); len(ids) > 0 {
+			lb.RegionPools[key] = ids
+		}
+	}
+
+	if len(lb.DefaultPools) == 0 {
+		err := fmt.Errorf("no pools to steer %s.%s to", plan.Host, plan.Domain)
+		report.Add(Result{Provider: c.Name(), Domain: plan.Domain, Host: plan.Host, Action: plan.LoadBalancer}, err)
+		return err
+	}
+	lb.FallbackPool = lb.DefaultPools[0]
+
+	if plan.LoadBalancer == ActionCreate {
+		fmt.Printf("Creating load balancer %s\n", lb.Name)
+		err = c.CreateLoadBalancer(plan.zoneID, lb)
+	} else {
+		fmt.Printf("Updating load balancer %s\n", lb.Name)
+		err = c.UpdateLoadBalancer(plan.zoneID, lb)
+	}
+	report.Add(Result{Provider: c.Name(), Domain: plan.Domain, Host: plan.Host, Action: plan.LoadBalancer, Country: "load balancer"}, err)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d writes failed to reconcile %s.%s on %s", failed, plan.Host, plan.Domain, c.Name())
+	}
+	return nil
+}
+
+// UnusedPools returns the managed pools of members none of the services of
+// the account steers to, given the members eligible for any of them.
+func UnusedPools(c *Cloudflare, steered []Member) ([]CloudflarePool, error) {
+	pools, err := c.ListPools()
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, member := range steered {
+		used[c.PoolName(member)] = true
+	}
+
+	var unused []CloudflarePool
+	for _, pool := range pools {
+		if !used[pool.Name] {
+			unused = append(unused, pool)
+		}
+	}
+	return unused, nil
+}
@@ -0,0 +1,180 @@
+package geodns
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EligibilityOptions controls when members at the required level become
+// eligible.
+type EligibilityOptions struct {
+	// Now is the time eligibility is evaluated at, the current time when
+	// zero.
+	Now time.Time
+
+	// Probation is how long a member has to be at the required level or
+	// above before it is eligible.
+	Probation time.Duration
+}
+
+func (opts EligibilityOptions) now() time.Time {
+	if opts.Now.IsZero() {
+		return time.Now()
+	}
+	return opts.Now
+}
+
+// EligibilityChange is a member becoming eligible or losing eligibility
+// for a service on a future date.
+type EligibilityChange struct {
+	Member   string    `json:"member"`
+	At       time.Time `json:"at"`
+	Eligible bool      `json:"eligible"`
+}
+
+// levelTimes returns when the member reached each level, leaving out levels
+// without a timestamp.
+func (m Member) levelTimes() map[int]time.Time {
+	times := make(map[int]time.Time)
+	for key, value := range m.LevelTimestamp {
+		level, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		times[level] = time.Unix(seconds, 0)
+	}
+	return times
+}
+
+// LevelAt returns the level of the member at time t. The current level
+// takes effect at its timestamp, so a promotion or demotion can be dated in
+// the future; until then the member holds the highest level it reached
+// before t. Members without a timestamp for the current level have had it
+// all along.
+func (m Member) LevelAt(t time.Time) int {
+	current := m.Level()
+	times := m.levelTimes()
+	since, ok := times[current]
+	if !ok || !since.After(t) {
+		return current
+	}
+
+	level := 0
+	for other, reached := range times {
+		if other != current && !reached.After(t) && other > level {
+			level = other
+		}
+	}
+	return level
+}
+
+// LevelSince returns since when the member has been at minLevel or above at
+// time t, and false when it is below minLevel at t. Only the last time
+// every level was reached is known, so the member counts as at minLevel
+// from the first level at or above it reached after the last level below
+// it. A current level without a timestamp counts as reached at the zero
+// time.
+func (m Member) LevelSince(minLevel int, t time.Time) (time.Time, bool) {
+	if m.LevelAt(t) < minLevel {
+		return time.Time{}, false
+	}
+
+	times := m.levelTimes()
+	if _, ok := times[m.Level()]; !ok {
+		return time.Time{}, true
+	}
+
+	var below time.Time
+	for level, reached := range times {
+		if level < minLevel && !reached.After(t) && reached.After(below) {
+			below = reached
+		}
+	}
+
+	var since time.Time
+	for level, reached := range times {
+		if level >= minLevel && !reached.After(t) && !reached.Before(below) && (since.IsZero() || reached.Before(since)) {
+			since = reached
+		}
+	}
+	return since, true
+}
+
+// EligibleAt reports whether the member is an active member with a location
+// that has been at minLevel or above for at least the probation at time t.
+// Its services addresses are left to MembersWithAddress, per record type.
+func (m Member) EligibleAt(minLevel int, probation time.Duration, t time.Time) bool {
+	lat, long := m.Coordinates()
+	if lat == 0 || long == 0 || !m.IsActive() {
+		return false
+	}
+	since, ok := m.LevelSince(minLevel, t)
+	return ok && (since.IsZero() || !since.Add(probation).After(t))
+}
+
+// UpcomingEligibility returns the members whose eligibility at minLevel
+// changes after opts.Now and within horizon, such as promoted members
+// finishing their probation, sorted by date.
+func UpcomingEligibility(members Members, minLevel int, opts EligibilityOptions, horizon time.Duration) []EligibilityChange {
+	now := opts.now()
+	end := now.Add(horizon)
+
+	var changes []EligibilityChange
+	for _, id := range sortedMemberIDs(members) {
+		member := members.Members[id]
+
+		// Eligibility can only change when a level is reached or a
+		// probation ends
+		var moments []time.Time
+		for _, reached := range member.levelTimes() {
+			moments = append(moments, reached, reached.Add(opts.Probation))
+		}
+		sort.Slice(moments, func(i, j int) bool {
+			return moments[i].Before(moments[j])
+		})
+
+		eligible := member.EligibleAt(minLevel, opts.Probation, now)
+		for _, moment := range moments {
+			if !moment.After(now) || moment.After(end) {
+				continue
+			}
+			if member.EligibleAt(minLevel, opts.Probation, moment) != eligible {
+				eligible = !eligible
+				changes = append(changes, EligibilityChange{Member: id, At: moment, Eligible: eligible})
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].At.Before(changes[j].At)
+	})
+	return changes
+}
+
+func sortedMemberIDs(members Members) []string {
+	var ids []string
+	for id := range members.Members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// PrintUpcomingEligibility writes the upcoming eligibility changes of a
+// service.
+func PrintUpcomingEligibility(w io.Writer, service string, changes []EligibilityChange) {
+	for _, change := range changes {
+		action := "stops serving"
+		if change.Eligible {
+			action = "starts serving"
+		}
+		fmt.Fprintf(w, "Service %s: %s %s on %s\n", service, change.Member, action, change.At.UTC().Format("2006-01-02 15:04 MST"))
+	}
+}
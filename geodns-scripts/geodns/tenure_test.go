@@ -0,0 +1,115 @@
+package geodns
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var tenureStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func day(n int) time.Time {
+	return tenureStart.AddDate(0, 0, n)
+}
+
+// tenured returns an active member at level current that reached the
+// levels on the given days.
+func tenured(current int, reached map[int]int) Member {
+	member := Member{CurrentLevel: strconv.Itoa(current), Active: "1", Lat: "50", Long: "8", LevelTimestamp: make(map[string]string)}
+	for level, n := range reached {
+		member.LevelTimestamp[strconv.Itoa(level)] = strconv.FormatInt(day(n).Unix(), 10)
+	}
+	return member
+}
+
+func TestLevelAt(t *testing.T) {
+	promoted := tenured(5, map[int]int{3: 0, 5: 10})
+	demoted := tenured(2, map[int]int{5: 0, 2: 20})
+	untimed := tenured(4, map[int]int{2: 0})
+	garbled := tenured(5, map[int]int{3: 0})
+	garbled.LevelTimestamp["5"] = "soon"
+
+	tests := []struct {
+		name   string
+		member Member
+		at     time.Time
+		want   int
+	}{
+		{"before any level", promoted, day(-1), 0},
+		{"before a promotion", promoted, day(5), 3},
+		{"at a promotion", promoted, day(10), 5},
+		{"after a promotion", promoted, day(11), 5},
+		{"before a future demotion", demoted, day(19), 5},
+		{"at a future demotion", demoted, day(20), 2},
+		{"current level without timestamp", untimed, day(-1), 4},
+		{"unparsable timestamp", garbled, day(-1), 5},
+	}
+
+	for _, test := range tests {
+		if got := test.member.LevelAt(test.at); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEligibleAt(t *testing.T) {
+	promoted := tenured(5, map[int]int{3: 0, 5: 10})
+	demoted := tenured(2, map[int]int{5: 0, 2: 20})
+	repromoted := tenured(5, map[int]int{5: 20, 3: 10})
+	higher := tenured(6, map[int]int{5: 0, 6: 5})
+	inactive := tenured(5, map[int]int{5: 0})
+	inactive.Active = "0"
+	unlocated := tenured(5, map[int]int{5: 0})
+	unlocated.Lat = ""
+	probation := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		member    Member
+		probation time.Duration
+		at        time.Time
+		want      bool
+	}{
+		{"below the level", promoted, 0, day(5), false},
+		{"promotion without probation", promoted, 0, day(10), true},
+		{"promotion during probation", promoted, probation, day(12), false},
+		{"promotion after probation", promoted, probation, day(17), true},
+		{"before a future demotion", demoted, probation, day(19), true},
+		{"at a future demotion", demoted, probation, day(20), false},
+		{"probation restarts after a demotion", repromoted, probation, day(22), false},
+		{"probation ends after a demotion", repromoted, probation, day(27), true},
+		{"higher levels count toward probation", higher, probation, day(8), true},
+		{"current level without timestamp", tenured(5, nil), probation, day(0), true},
+		{"inactive member", inactive, 0, day(30), false},
+		{"member without location", unlocated, 0, day(30), false},
+	}
+
+	for _, test := range tests {
+		if got := test.member.EligibleAt(5, test.probation, test.at); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUpcomingEligibility(t *testing.T) {
+	members := Members{Members: map[string]Member{
+		"promoted": tenured(5, map[int]int{3: 0, 5: 10}),
+		"demoted":  tenured(2, map[int]int{5: 0, 2: 20}),
+		"distant":  tenured(5, map[int]int{3: 0, 5: 60}),
+		"settled":  tenured(5, map[int]int{5: 0}),
+	}}
+	opts := EligibilityOptions{Now: day(12), Probation: 7 * 24 * time.Hour}
+
+	want := []EligibilityChange{
+		{Member: "promoted", At: day(17), Eligible: true},
+		{Member: "demoted", At: day(20), Eligible: false},
+	}
+	got := UpcomingEligibility(members, 5, opts, 30*24*time.Hour)
+	for i := range got {
+		got[i].At = got[i].At.UTC()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
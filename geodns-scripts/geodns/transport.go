@@ -0,0 +1,124 @@
+package geodns
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport is the HTTP layer shared by the providers. It spaces requests to
+// stay under the provider's rate limit, bounds every request with a timeout
+// and retries calls that failed transiently.
+//
+// Rate limited calls are always retried, since the provider rejected them
+// before doing anything. Network errors and 5xx responses are only retried
+// for idempotent calls, as the provider may have applied the change before
+// failing.
+type Transport struct {
+	Client *http.Client
+
+	// RateLimit is the number of requests per second, 0 for no limit.
+	RateLimit float64
+
+	// Retries is the number of retries after the first attempt. Backoff is
+	// the delay before the first retry and doubles up to MaxBackoff.
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewTransport returns a transport with a request timeout and four retries
+// starting one second apart.
+func NewTransport(rateLimit float64, timeout time.Duration) *Transport {
+	return &Transport{
+		Client:     &http.Client{Timeout: timeout},
+		RateLimit:  rateLimit,
+		Retries:    4,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// defaultTransport is used by providers created without a transport.
+var defaultTransport = NewTransport(0, 30*time.Second)
+
+// Call runs call until it succeeds, fails permanently or runs out of
+// retries. The returned provider error records the number of attempts.
+func (t *Transport) Call(idempotent bool, call func(client *http.Client) error) error {
+	backoff := t.Backoff
+	for attempt := 1; ; attempt++ {
+		t.wait()
+		err := call(t.Client)
+		if err == nil {
+			return nil
+		}
+
+		var perr *ProviderError
+		if errors.As(err, &perr) {
+			perr.Attempts = attempt
+		}
+		if attempt > t.Retries || !retryable(err, idempotent) {
+			return err
+		}
+
+		delay := backoff
+		if perr != nil && perr.RetryAfter > delay {
+			delay = perr.RetryAfter
+		}
+		// Up to 20% jitter keeps parallel runs from retrying in lockstep
+		time.Sleep(delay + time.Duration(rand.Int63n(int64(delay)/5+1)))
+
+		backoff *= 2
+		if backoff > t.MaxBackoff {
+			backoff = t.MaxBackoff
+		}
+	}
+}
+
+// wait blocks until the rate limit allows the next request.
+func (t *Transport) wait() {
+	if t.RateLimit <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	start := t.next
+	if start.Before(now) {
+		start = now
+	}
+	t.next = start.Add(time.Duration(float64(time.Second) / t.RateLimit))
+	t.mu.Unlock()
+
+	time.Sleep(start.Sub(now))
+}
+
+// retryable reports whether a failed call may be retried.
+func retryable(err error, idempotent bool) bool {
+	if errors.Is(err, ErrRateLimit) {
+		return true
+	}
+	if !idempotent {
+		return false
+	}
+	if errors.Is(err, ErrNetwork) {
+		return true
+	}
+	var perr *ProviderError
+	return errors.As(err, &perr) && perr.StatusCode >= 500
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,325 @@
+package geodns
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxLevel is the highest IBP membership level.
+const MaxLevel = 7
+
+// Problem is a violation found while validating a configuration file.
+type Problem struct {
+	File    string
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Key == "" {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Key, p.Message)
+}
+
+type validator struct {
+	file     string
+	problems []Problem
+}
+
+func (v *validator) add(key string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{File: v.file, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// decode strictly unmarshals a file, turning syntax and type errors into
+// problems with a line and column.
+func (v *validator) decode(value interface{}) bool {
+	fileContents, err := ReadSource(v.file)
+	if err != nil {
+		v.add("", "%v", err)
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(fileContents))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(value)
+	if err == nil {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, col := position(fileContents, syntaxErr.Offset)
+		v.add(fmt.Sprintf("line %d column %d", line, col), "%v", err)
+	case errors.As(err, &typeErr):
+		line, col := position(fileContents, typeErr.Offset)
+		v.add(typeErr.Field, "line %d column %d: expected %s, got %s", line, col, typeErr.Type, typeErr.Value)
+	default:
+		v.add("", "%v", err)
+	}
+	return false
+}
+
+func position(contents []byte, offset int64) (int, int) {
+	if offset > int64(len(contents)) {
+		offset = int64(len(contents))
+	}
+	before := contents[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+func (v *validator) level(key string, value string) {
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 || level > MaxLevel {
+		v.add(key, "unknown level %q, expected 0 to %d", value, MaxLevel)
+	}
+}
+
+func (v *validator) coordinates(key string, lat string, long string) {
+	if value, err := strconv.ParseFloat(lat, 64); err != nil || value < -90 || value > 90 {
+		v.add(key+".latitude", "invalid latitude %q", lat)
+	}
+	if value, err := strconv.ParseFloat(long, 64); err != nil || value < -180 || value > 180 {
+		v.add(key+".longitude", "invalid longitude %q", long)
+	}
+}
+
+func (v *validator) endpoint(key string, endpoint string) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "wss" && u.Scheme != "ws") || u.Host == "" {
+		v.add(key, "invalid websocket endpoint %q", endpoint)
+	}
+}
+
+// ValidateMembers checks members.json. The members are returned for use in
+// ValidateServices even when there are problems.
+func ValidateMembers(filePath string) (Members, []Problem) {
+	v := &validator{file: filePath}
+
+	var members Members
+	if !v.decode(&members) {
+		return members, v.problems
+	}
+
+	var ids []string
+	for id, member := range members.Members {
+		member.ID = id
+		members.Members[id] = member
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		member := members.Members[id]
+		key := "members." + id
+
+		if member.Name == "" {
+			v.add(key+".name", "missing name")
+		}
+		v.level(key+".current_level", member.CurrentLevel)
+		if member.Active != "0" && member.Active != "1" {
+			v.add(key+".active", "expected \"0\" or \"1\", got %q", member.Active)
+		}
+
+		// Levels that were never reached have an empty timestamp
+		for _, level := range sortedKeys(member.LevelTimestamp) {
+			timestamp := member.LevelTimestamp[level]
+			v.level(key+".level_timestamp."+level, level)
+			if _, err := strconv.ParseInt(timestamp, 10, 64); timestamp != "" && err != nil {
+				v.add(key+".level_timestamp."+level, "invalid unix timestamp %q", timestamp)
+			}
+		}
+
+		if member.ServicesAddress != "" {
+			ip := net.ParseIP(member.ServicesAddress)
+			if ip == nil || ip.To4() == nil {
+				v.add(key+".services_address", "invalid IPv4 address %q", member.ServicesAddress)
+			}
+		}
+		if member.ServicesAddressV6 != "" {
+			ip := net.ParseIP(member.ServicesAddressV6)
+			if ip == nil || ip.To4() != nil {
+				v.add(key+".services_address_v6", "invalid IPv6 address %q", member.ServicesAddressV6)
+			}
+		}
+		if member.Region != "" && !ValidRegion(member.Region) {
+			v.add(key+".region", "unknown region %q, use one of %s", member.Region, strings.Join(Regions, ", "))
+		}
+
+		// Inactive members may not have a location yet
+		if member.IsActive() || member.Lat != "" || member.Long != "" {
+			v.coordinates(key, member.Lat, member.Long)
+		}
+
+		for _, chain := range sortedKeys(member.Endpoints) {
+			v.endpoint(key+".endpoints."+chain, member.Endpoints[chain])
+		}
+	}
+	return members, v.problems
+}
+
+// ValidateServices checks services.json against the loaded members.
+func ValidateServices(filePath string, members Members) []Problem {
+	v := &validator{file: filePath}
+
+	var services Services
+	if !v.decode(&services) {
+		return v.problems
+	}
+
+	for _, name := range services.Names() {
+		service := services.Services[name]
+		key := "services." + name
+
+		if host, domain := SplitServiceName(name); host == "" || !strings.Contains(domain, ".") {
+			v.add(key, "service name is not a host in a domain")
+		}
+		v.level(key+".level_required", service.LevelRequired)
+
+		for _, chain := range service.Chains() {
+			v.endpoint(key+".endpoints."+chain, service.Endpoints[chain])
+		}
+
+		seen := make(map[string]bool)
+		for i, id := range service.Members {
+			memberKey := fmt.Sprintf("%s.members[%d]", key, i)
+			if seen[id] {
+				v.add(memberKey, "duplicate member %q", id)
+			}
+			seen[id] = true
+
+			member, ok := members.Members[id]
+			if !ok {
+				v.add(memberKey, "member %q does not exist in members.json", id)
+				continue
+			}
+			if member.Level() < service.Level() {
+				v.add(memberKey, "member %q is level %s, service requires %s", id, member.CurrentLevel, service.LevelRequired)
+			}
+		}
+	}
+	return v.problems
+}
+
+// ValidateCountries checks a countries file. Country codes may only repeat
+// for region entries, such as "United States - Region I".
+func ValidateCountries(filePath string) []Problem {
+	v := &validator{file: filePath}
+
+	var countries Countries
+	if !v.decode(&countries) {
+		return v.problems
+	}
+
+	codes := make(map[string]int)
+	geodnsIds := make(map[int]int)
+	easydnsIds := make(map[int]int)
+	route53Keys := make(map[string]int)
+	cloudflareKeys := make(map[string]int)
+	for i, country := range countries.Country {
+		key := fmt.Sprintf("countries[%d]", i)
+
+		if country.Name == "" {
+			v.add(key+".name", "missing name")
+		}
+		if len(country.CC) != 2 || strings.ToUpper(country.CC) != country.CC {
+			v.add(key+".country_code", "invalid country code %q", country.CC)
+		}
+		v.coordinates(key, country.Lat, country.Long)
+		if country.Region != "" && !ValidRegion(country.Region) {
+			v.add(key+".region", "unknown region %q, use one of %s", country.Region, strings.Join(Regions, ", "))
+		}
+
+		if first, ok := codes[country.CC]; ok {
+			other := countries.Country[first]
+			if !strings.Contains(country.Name, " - ") && !strings.Contains(other.Name, " - ") {
+				v.add(key+".country_code", "%q is used by both %s and %s", country.CC, other.Name, country.Name)
+			}
+		} else {
+			codes[country.CC] = i
+		}
+
+		if country.GeodnsId != 0 {
+			if first, ok := geodnsIds[country.GeodnsId]; ok {
+				v.add(key+".geodns-id", "%d is also used by %s", country.GeodnsId, countries.Country[first].Name)
+			} else {
+				geodnsIds[country.GeodnsId] = i
+			}
+		}
+		if country.EasydnsId != 0 {
+			if first, ok := easydnsIds[country.EasydnsId]; ok {
+				v.add(key+".easydns_id", "%d is also used by %s", country.EasydnsId, countries.Country[first].Name)
+			} else {
+				easydnsIds[country.EasydnsId] = i
+			}
+		}
+		if geo := country.Route53; geo != nil {
+			switch {
+			case geo.ContinentCode != "" && (geo.CountryCode != "" || geo.SubdivisionCode != ""):
+				v.add(key+".route53", "a continent cannot have a country or subdivision code")
+			case geo.ContinentCode == "" && geo.CountryCode == "":
+				v.add(key+".route53", "missing continent or country code")
+			case geo.SubdivisionCode != "" && geo.CountryCode == "*":
+				v.add(key+".route53", "the default location cannot have a subdivision code")
+			}
+			if first, ok := route53Keys[geo.key()]; ok {
+				v.add(key+".route53", "%s is also used by %s", geo.key(), countries.Country[first].Name)
+			} else {
+				route53Keys[geo.key()] = i
+			}
+		}
+		if cf := country.Cloudflare; cf != "" {
+			if len(cf) != 2 || strings.ToUpper(cf) != cf {
+				v.add(key+".cloudflare", "invalid country code %q", cf)
+			}
+			if first, ok := cloudflareKeys[cf]; ok {
+				v.add(key+".cloudflare", "%s is also used by %s", cf, countries.Country[first].Name)
+			} else {
+				cloudflareKeys[cf] = i
+			}
+		}
+		if region := country.CloudflareRegion; region != "" {
+			if !IsCloudflareRegion(region) {
+				v.add(key+".cloudflare_region", "unknown region %q, use one of %s", region, strings.Join(CloudflareRegions, ", "))
+			}
+			if country.Cloudflare != "" {
+				v.add(key, "cloudflare and cloudflare_region are both set")
+			}
+			if first, ok := cloudflareKeys["region "+region]; ok {
+				v.add(key+".cloudflare_region", "%s is also used by %s", region, countries.Country[first].Name)
+			} else {
+				cloudflareKeys["region "+region] = i
+			}
+		}
+		if country.GeodnsId == 0 && country.EasydnsId == 0 && country.Route53 == nil && country.Cloudflare == "" && country.CloudflareRegion == "" {
+			v.add(key, "missing geo location id")
+		}
+	}
+
+	// Route53 does not answer queries matching none of the locations of a
+	// record, so Route53 files need the default location
+	if _, ok := route53Keys["default"]; len(route53Keys) > 0 && !ok {
+		v.add("countries", "missing the Route53 default location, an entry with route53 country code \"*\"")
+	}
+	return v.problems
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
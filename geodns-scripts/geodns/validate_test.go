@@ -0,0 +1,36 @@
+package geodns
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCountriesRoute53Default(t *testing.T) {
+	const germany = `{"name": "Germany", "country_code": "DE", "latitude": "51.1657", "longitude": "10.4515", "route53": {"country_code": "DE"}}`
+	const fallback = `{"name": "Default - Other locations", "country_code": "DE", "latitude": "50.1109", "longitude": "8.6821", "route53": {"country_code": "*"}}`
+	const cloudns = `{"name": "Germany", "country_code": "DE", "latitude": "51.1657", "longitude": "10.4515", "geodns-id": 83}`
+
+	tests := []struct {
+		countries string
+		problem   string
+	}{
+		{germany + "," + fallback, ""},
+		{germany, "missing the Route53 default location"},
+		{cloudns, ""},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "countries.json")
+		if err := os.WriteFile(path, []byte(`{"countries": [`+test.countries+`]}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		problems := ValidateCountries(path)
+		switch {
+		case test.problem == "" && len(problems) > 0:
+			t.Errorf("%s: unexpected problems %v", test.countries, problems)
+		case test.problem != "" && (len(problems) != 1 || !strings.Contains(problems[0].String(), test.problem)):
+			t.Errorf("%s: got %v, want %q", test.countries, problems, test.problem)
+		}
+	}
+}
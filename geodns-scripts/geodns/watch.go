@@ -0,0 +1,42 @@
+package geodns
+
+import (
+	"path/filepath"
+)
+
+// Watcher signals on C when one of the watched files changes. Bursts of
+// changes may be coalesced into a single signal.
+type Watcher struct {
+	C <-chan struct{}
+
+	stop func()
+}
+
+// WatchFiles watches the local files among the given locations, see
+// NewSource. URLs and git refs are ignored.
+func WatchFiles(locations []string) (*Watcher, error) {
+	var paths []string
+	for _, location := range locations {
+		if file, ok := NewSource(location).(FileSource); ok {
+			path, err := filepath.Abs(string(file))
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+	}
+	return watchFiles(paths)
+}
+
+// Close stops watching.
+func (w *Watcher) Close() {
+	w.stop()
+}
+
+// notify signals without blocking, as one pending signal is enough.
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package geodns
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// watchFiles uses inotify on the directories of the files, so that files
+// replaced by editors or git checkouts are still noticed. The descriptor is
+// non-blocking and read through the runtime poller, so that closing it ends
+// a pending read.
+func watchFiles(paths []string) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[int32]map[string]bool)
+	dirs := make(map[string]int32)
+	for _, path := range paths {
+		dir, name := filepath.Split(path)
+		wd, ok := dirs[dir]
+		if !ok {
+			mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE)
+			w, err := syscall.InotifyAddWatch(fd, dir, mask)
+			if err != nil {
+				syscall.Close(fd)
+				return nil, err
+			}
+			wd = int32(w)
+			dirs[dir] = wd
+			watched[wd] = make(map[string]bool)
+		}
+		watched[wd][name] = true
+	}
+
+	file := os.NewFile(uintptr(fd), "inotify")
+	c := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil || n <= 0 {
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				name := string(bytes.TrimRight(nameBytes, "\x00"))
+				if watched[event.Wd][name] {
+					notify(c)
+				}
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+			}
+		}
+	}()
+
+	stop := func() {
+		file.Close()
+		<-done
+	}
+	return &Watcher{C: c, stop: stop}, nil
+}
@@ -0,0 +1,47 @@
+//go:build !linux
+
+package geodns
+
+import (
+	"os"
+	"time"
+)
+
+// watchFiles polls the modification time of the files where inotify is not
+// available.
+func watchFiles(paths []string) (*Watcher, error) {
+	modTime := func(path string) time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+
+	last := make(map[string]time.Time)
+	for _, path := range paths {
+		last[path] = modTime(path)
+	}
+
+	c := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			for _, path := range paths {
+				if current := modTime(path); !current.Equal(last[path]) {
+					last[path] = current
+					notify(c)
+				}
+			}
+		}
+	}()
+
+	return &Watcher{C: c, stop: func() { close(done) }}, nil
+}
@@ -0,0 +1,49 @@
+package geodns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "members.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := WatchFiles([]string{path, "https://example.com/countries.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the file the way editors and git checkouts do
+	tmp := filepath.Join(dir, "members.json.tmp")
+	if err := os.WriteFile(tmp, []byte(`{"members":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The poller on other systems compares modification times every few
+	// seconds
+	select {
+	case <-watcher.C:
+	case <-time.After(15 * time.Second):
+		t.Fatal("no change signalled")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		watcher.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the watcher")
+	}
+}
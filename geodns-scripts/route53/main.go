@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ibp-network/geodns-manager/geodns"
+)
+
+func main() {
+	membersFile := flag.String("members", "./members.json", "Path, URL or git:<dir>@<ref>:<file> of members.json")
+	servicesFile := flag.String("services", "./services.json", "Path, URL or git:<dir>@<ref>:<file> of services.json")
+	countriesFile := flag.String("countries", "./route53-countries.json", "Path to the Route53 countries file")
+	credentialsFile := flag.String("credentials-file", "", "JSON file with provider credentials per domain, readable by the owner only")
+	credentialsCommand := flag.String("credentials-command", "", "Command printing the credentials, given the provider and domain as $1 and $2 or {provider} and {domain}")
+	endpoint := flag.String("endpoint", "", "Route53 API endpoint, such as a local fake of the API")
+	rateLimit := flag.Float64("rate-limit", geodns.Route53RateLimit, "Route53 requests per second, shared by all domains")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of a single Route53 request")
+	retries := flag.Int("retries", 4, "Retries of throttled or transiently failed Route53 requests")
+	ttl := flag.Int("ttl", 60, "TTL of the geo record sets")
+	answers := flag.Int("answers", 1, "Number of nearest members published per geo location (lowering it needs -prune)")
+	regionPenalty := flag.Float64("region-penalty", 0, "Latency in ms added to members outside the region of a country, preferring members in its region")
+	strictRegions := flag.Bool("strict-regions", false, "Only assign members outside the region of a country when none is in its region")
+	hysteresis := flag.Float64("hysteresis", 0, "Latency in ms another member has to win by before a country moves off its current member")
+	planOnly := flag.Bool("plan", false, "Print the record changes without applying them")
+	planFile := flag.String("json", "", "Write the record changes as JSON to this file")
+	prune := flag.Bool("prune", false, "Delete orphaned and duplicate geo records of the managed hosts")
+	probation := flag.Duration("probation", 0, "Time a member has to be at the required level before it serves a service, such as 720h")
+	upcoming := flag.Duration("upcoming", 30*24*time.Hour, "Report members starting or stopping to serve a service within this time")
+	flag.Parse()
+
+	// Load Member JSON File
+	members, err := geodns.LoadMembers(*membersFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	// Load Services JSON File
+	services, err := geodns.LoadServices(*servicesFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	// Load Countries JSON File
+	countries, err := geodns.LoadCountries(*countriesFile)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded countries: %d\n", len(countries.Country))
+
+	credentials := geodns.NewCredentialChain(*credentialsFile, *credentialsCommand)
+	transport := geodns.NewTransport(*rateLimit, *timeout)
+	transport.Retries = *retries
+
+	// Every domain may use its own credentials
+	providers := make(map[string]geodns.Provider)
+	report := &geodns.Report{}
+	var plans []geodns.Plan
+	eligibility := geodns.EligibilityOptions{Probation: *probation}
+	for _, name := range services.Names() {
+		service := services.Services[name]
+		host, domain := geodns.SplitServiceName(name)
+
+		validMembers := service.EligibleMembersAt(members, eligibility)
+		fmt.Printf("Service %s: %d valid members from %d listed (level %d)\n", name, len(validMembers), len(service.Members), service.Level())
+		geodns.PrintUpcomingEligibility(os.Stdout, name, service.UpcomingEligibility(members, eligibility, *upcoming))
+
+		// Never prune a service down to nothing because nobody is eligible
+		if len(validMembers) == 0 {
+			fmt.Printf("Service %s: no valid members, skipping\n", name)
+			report.Add(geodns.Result{Provider: "route53", Domain: domain, Host: host, Action: "assign"}, fmt.Errorf("no valid members"))
+			continue
+		}
+
+		provider, ok := providers[domain]
+		if !ok {
+			creds, err := credentials.Resolve("route53", domain)
+			if err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+				report.Add(geodns.Result{Provider: "route53", Domain: domain, Host: host, Action: "login"}, err)
+				continue
+			}
+			route53 := geodns.NewRoute53(creds.User, creds.Secret)
+			route53.BaseURL = *endpoint
+			route53.Transport = transport
+			provider = route53
+			providers[domain] = provider
+		}
+
+		// Assign countries to members per record type, members without
+		// IPv6 are only left out of the AAAA record sets
+		for _, recordType := range geodns.RecordTypes {
+			typeMembers := geodns.MembersWithAddress(validMembers, recordType)
+			if len(typeMembers) == 0 {
+				fmt.Printf("Service %s: no valid members with %s addresses, skipping %s records\n", name, recordType, recordType)
+				continue
+			}
+
+			// Countries only move off their current members when another
+			// member is faster by the hysteresis
+			assignOpts := geodns.AssignOptions{Answers: *answers, RegionPenalty: *regionPenalty, StrictRegions: *strictRegions}
+			if *hysteresis > 0 {
+				assignOpts.Current, err = geodns.CurrentMembers(provider, domain, host, recordType, countries.Country, typeMembers)
+				if err != nil {
+					fmt.Printf("%s: %v\n", name, err)
+					report.Add(geodns.Result{Provider: provider.Name(), Domain: domain, Host: host, Action: "list"}, err)
+					continue
+				}
+				assignOpts.Hysteresis = *hysteresis
+			}
+			assignments := geodns.AssignCountries(countries.Country, typeMembers, assignOpts)
+			geodns.PrintOutOfRegion(os.Stdout, name+" "+recordType, assignments)
+
+			plan, err := geodns.NewPlan(provider, domain, host, assignments, geodns.PlanOptions{TTL: *ttl, Type: recordType, Prune: *prune})
+			if err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+				report.Add(geodns.Result{Provider: provider.Name(), Domain: domain, Host: host, Action: "list"}, err)
+				continue
+			}
+			plan.Print(os.Stdout)
+			plans = append(plans, plan)
+
+			if *planOnly {
+				continue
+			}
+			if err := geodns.Apply(provider, plan, report); err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+			}
+		}
+	}
+
+	if *planFile != "" {
+		planBytes, err := json.MarshalIndent(plans, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(*planFile, planBytes, 0644)
+		}
+		if err != nil {
+			fmt.Printf("Error writing plan: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	report.Print(os.Stdout)
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
+}